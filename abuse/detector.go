@@ -0,0 +1,219 @@
+package abuse
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/models"
+)
+
+// Config holds detection thresholds and enforcement durations
+type Config struct {
+	Window             time.Duration // Window for rate limit hits and distinct networks
+	RateLimitHits      int           // 429 responses within Window that trigger a throttle
+	DistinctNetworks   int           // Client networks within Window that trigger a suspension
+	ErrorWindow        time.Duration // Window for error bursts
+	ErrorBurstRequests int           // Minimum requests within ErrorWindow before the ratio is checked
+	ErrorBurstRatio    float64       // Share of client errors that triggers a throttle
+	ThrottleFactor     float64       // Fraction of the key's rate limit kept while throttled
+	ThrottleDuration   time.Duration
+	SuspendDuration    time.Duration
+}
+
+// DefaultConfig returns conservative thresholds
+func DefaultConfig() Config {
+	return Config{
+		Window:             10 * time.Minute,
+		RateLimitHits:      100,
+		DistinctNetworks:   20,
+		ErrorWindow:        time.Minute,
+		ErrorBurstRequests: 50,
+		ErrorBurstRatio:    0.8,
+		ThrottleFactor:     0.25,
+		ThrottleDuration:   15 * time.Minute,
+		SuspendDuration:    time.Hour,
+	}
+}
+
+// keyActivity is the recent request history of one key
+type keyActivity struct {
+	rateLimited []time.Time
+	networks    map[string]time.Time // Client network -> last seen
+	requests    []time.Time
+	errors      []time.Time
+	lastSeen    time.Time
+}
+
+// Detector watches per-key traffic and throttles or suspends keys that
+// persistently hit rate limits, look leaked, or produce error bursts
+type Detector struct {
+	cfg       Config
+	keyStore  *auth.KeyStore
+	notifiers []Notifier
+	keys      map[string]*keyActivity
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// NewDetector creates a detector that records enforcement on the key store
+func NewDetector(cfg Config, keyStore *auth.KeyStore, notifiers ...Notifier) *Detector {
+	return &Detector{
+		cfg:       cfg,
+		keyStore:  keyStore,
+		notifiers: notifiers,
+		keys:      make(map[string]*keyActivity),
+		lastSweep: time.Now(),
+	}
+}
+
+// Observe records the outcome of a request made with a key. clientIP is empty
+// for requests that don't count toward the leaked-key rule.
+func (d *Detector) Observe(key *models.APIKey, clientIP string, status int) {
+	now := time.Now()
+
+	d.mu.Lock()
+	d.sweep(now)
+
+	activity, exists := d.keys[key.ID]
+	if !exists {
+		activity = &keyActivity{networks: make(map[string]time.Time)}
+		d.keys[key.ID] = activity
+	}
+
+	activity.lastSeen = now
+	if clientIP != "" && !spreadByDesign(key) {
+		activity.networks[clientNetwork(clientIP)] = now
+	}
+	activity.requests = append(pruneBefore(activity.requests, now.Add(-d.cfg.ErrorWindow)), now)
+	activity.errors = pruneBefore(activity.errors, now.Add(-d.cfg.ErrorWindow))
+	activity.rateLimited = pruneBefore(activity.rateLimited, now.Add(-d.cfg.Window))
+
+	switch {
+	case status == 429:
+		activity.rateLimited = append(activity.rateLimited, now)
+	case status >= 400 && status < 500:
+		activity.errors = append(activity.errors, now)
+	}
+
+	for network, lastSeen := range activity.networks {
+		if now.Sub(lastSeen) > d.cfg.Window {
+			delete(activity.networks, network)
+		}
+	}
+
+	enforcement := d.evaluate(key, activity, now)
+	d.mu.Unlock()
+
+	if enforcement == nil {
+		return
+	}
+
+	if d.keyStore.ApplyEnforcement(key.ID, enforcement, auth.ActorAbuseDetector) {
+		alert := Alert{
+			KeyID:       key.ID,
+			KeyName:     key.Name,
+			Enforcement: enforcement,
+		}
+		for _, notifier := range d.notifiers {
+			notifier.Notify(alert)
+		}
+	}
+}
+
+// evaluate checks the rules in order of severity (caller must hold the lock).
+// The history behind a triggered rule is reset so it does not fire again at once.
+func (d *Detector) evaluate(key *models.APIKey, activity *keyActivity, now time.Time) *models.KeyEnforcement {
+	if len(activity.networks) >= d.cfg.DistinctNetworks {
+		reason := fmt.Sprintf("possible leaked key: used from %d networks within %s", len(activity.networks), d.cfg.Window)
+		activity.networks = make(map[string]time.Time)
+		return d.enforcement(models.EnforcementSuspended, reason, 0, now)
+	}
+
+	if len(activity.rateLimited) >= d.cfg.RateLimitHits {
+		reason := fmt.Sprintf("persistent rate limiting: %d rejected requests within %s", len(activity.rateLimited), d.cfg.Window)
+		activity.rateLimited = nil
+		return d.enforcement(models.EnforcementThrottled, reason, d.throttledLimit(key.RateLimit), now)
+	}
+
+	if len(activity.requests) >= d.cfg.ErrorBurstRequests {
+		ratio := float64(len(activity.errors)) / float64(len(activity.requests))
+		if ratio >= d.cfg.ErrorBurstRatio {
+			reason := fmt.Sprintf("error burst: %.0f%% of %d requests failed within %s", ratio*100, len(activity.requests), d.cfg.ErrorWindow)
+			activity.requests, activity.errors = nil, nil
+			return d.enforcement(models.EnforcementThrottled, reason, d.throttledLimit(key.RateLimit), now)
+		}
+	}
+
+	return nil
+}
+
+// enforcement builds an enforcement of the configured duration
+func (d *Detector) enforcement(action, reason string, rateLimit int, now time.Time) *models.KeyEnforcement {
+	duration := d.cfg.ThrottleDuration
+	if action == models.EnforcementSuspended {
+		duration = d.cfg.SuspendDuration
+	}
+
+	return &models.KeyEnforcement{
+		Action:    action,
+		Reason:    reason,
+		RateLimit: rateLimit,
+		Since:     now,
+		Until:     now.Add(duration),
+	}
+}
+
+// throttledLimit scales a rate limit down, keeping at least one request per minute
+func (d *Detector) throttledLimit(limit int) int {
+	throttled := int(float64(limit) * d.cfg.ThrottleFactor)
+	if throttled < 1 {
+		throttled = 1
+	}
+	return throttled
+}
+
+// sweep forgets keys without recent traffic (caller must hold the lock)
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < time.Minute {
+		return
+	}
+
+	for id, activity := range d.keys {
+		if now.Sub(activity.lastSeen) > d.cfg.Window {
+			delete(d.keys, id)
+		}
+	}
+	d.lastSweep = now
+}
+
+// spreadByDesign reports whether a key is meant to be used from many networks:
+// browser keys restricted to origins are called from every visitor's address
+func spreadByDesign(key *models.APIKey) bool {
+	return len(key.AllowedOrigins) > 0
+}
+
+// pruneBefore drops timestamps older than cutoff from a time-ordered slice
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// clientNetwork groups addresses by network (/24 for IPv4, /48 for IPv6) as
+// a stand-in for ASN lookups, so one client rotating addresses counts once
+func clientNetwork(clientIP string) string {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return clientIP
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
@@ -0,0 +1,64 @@
+package abuse
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// Alert describes an automatic enforcement for admins
+type Alert struct {
+	KeyID       string                 `json:"key_id"`
+	KeyName     string                 `json:"key_name"`
+	Enforcement *models.KeyEnforcement `json:"enforcement"`
+}
+
+// Notifier delivers alerts to admins
+type Notifier interface {
+	Notify(alert Alert)
+}
+
+// LogNotifier writes alerts to the server log
+type LogNotifier struct{}
+
+// Notify logs the alert
+func (LogNotifier) Notify(alert Alert) {
+	log.Printf("ABUSE: key %s (%s) %s until %s: %s",
+		alert.KeyID, alert.KeyName, alert.Enforcement.Action,
+		alert.Enforcement.Until.Format(time.RFC3339), alert.Enforcement.Reason)
+}
+
+// WebhookNotifier posts alerts as JSON to a URL, e.g. a Slack or incident webhook
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Notify posts the alert in the background so requests are never delayed
+func (wn *WebhookNotifier) Notify(alert Alert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		resp, err := wn.client.Post(wn.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Error sending abuse alert webhook: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// Audit actors
+const (
+	ActorAdmin         = "admin"
+	ActorAbuseDetector = "abuse_detector"
+)
+
+// auditLogSize is the number of entries retained in memory
+const auditLogSize = 1000
+
+// AuditLog keeps the most recent key management events
+type AuditLog struct {
+	entries []models.AuditEntry
+	mu      sync.RWMutex
+}
+
+// NewAuditLog creates an empty audit log
+func NewAuditLog() *AuditLog {
+	return &AuditLog{
+		entries: make([]models.AuditEntry, 0, 64),
+	}
+}
+
+// Record appends an entry, dropping the oldest once the log is full
+func (al *AuditLog) Record(actor, action, keyID, details string) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	al.entries = append(al.entries, models.AuditEntry{
+		Time:    time.Now(),
+		Actor:   actor,
+		Action:  action,
+		KeyID:   keyID,
+		Details: details,
+	})
+
+	if len(al.entries) > auditLogSize {
+		al.entries = al.entries[len(al.entries)-auditLogSize:]
+	}
+}
+
+// List returns up to limit entries, newest first, optionally filtered by key ID
+func (al *AuditLog) List(keyID string, limit int) []models.AuditEntry {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	result := make([]models.AuditEntry, 0, limit)
+	for i := len(al.entries) - 1; i >= 0 && len(result) < limit; i-- {
+		if keyID != "" && al.entries[i].KeyID != keyID {
+			continue
+		}
+		result = append(result, al.entries[i])
+	}
+
+	return result
+}
@@ -2,60 +2,133 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/daiwikmh/origami/counters"
 	"github.com/daiwikmh/origami/models"
 )
 
-// KeyStore manages API keys in memory
+// ErrKeyNotFound is returned when an operation references an unknown key
+var ErrKeyNotFound = errors.New("api key not found")
+
+// ErrRegistryUnavailable is returned when a key change can't be stored in the
+// shared key registry
+var ErrRegistryUnavailable = errors.New("api key registry unavailable")
+
+// errUnchanged tells modify that a change was not needed
+var errUnchanged = errors.New("api key unchanged")
+
+// DefaultKeyName names the key created for testing on first start
+const DefaultKeyName = "Default Test Key"
+
+// defaultKeyClaim marks the default key as created in the shared counter store
+const (
+	defaultKeyClaim    = "keys:default"
+	defaultKeyClaimTTL = 100 * 365 * 24 * time.Hour
+)
+
+// KeyStore manages API keys. Keys are cached in memory and, when a shared
+// registry is configured, stored there so every replica serves the same keys.
 type KeyStore struct {
-	keys      map[string]*models.APIKey
-	rateLimit map[string]*models.RateLimitInfo
-	mu        sync.RWMutex
+	keys     map[string]*models.APIKey
+	ids      map[string]string // key ID -> key
+	registry KeyRegistry       // Shared keys, nil keeps keys in this process
+	counters counters.Store    // Rate limit windows and usage counters
+	audit    *AuditLog
+	mu       sync.RWMutex
+	writeMu  sync.Mutex // Serializes key changes
 }
 
-// NewKeyStore creates a new key store
-func NewKeyStore() *KeyStore {
+// NewKeyStore creates a new key store using the given counter store. With a
+// registry, keys are loaded from it and refreshed every syncInterval.
+func NewKeyStore(counterStore counters.Store, registry KeyRegistry, syncInterval time.Duration) *KeyStore {
 	store := &KeyStore{
-		keys:      make(map[string]*models.APIKey),
-		rateLimit: make(map[string]*models.RateLimitInfo),
+		keys:     make(map[string]*models.APIKey),
+		ids:      make(map[string]string),
+		registry: registry,
+		counters: counterStore,
+		audit:    NewAuditLog(),
 	}
 
-	// Create a default API key for testing
-	defaultKey := store.GenerateKey("Default Test Key", 100)
-	store.keys[defaultKey.Key] = defaultKey
+	if registry != nil {
+		if err := store.sync(); err != nil {
+			log.Printf("Failed to load API keys from %s registry: %v", registry.Backend(), err)
+		}
+		go store.syncLoop(syncInterval)
+	}
+
+	// Create a default API key for testing, once across replicas
+	if store.claimDefaultKey() {
+		if _, err := store.GenerateKey(DefaultKeyName, 100, KeyOptions{}); err != nil {
+			log.Printf("Failed to create default API key: %v", err)
+		}
+	}
 
 	return store
 }
 
-// GenerateKey creates a new API key
-func (ks *KeyStore) GenerateKey(name string, rateLimit int) *models.APIKey {
-	ks.mu.Lock()
-	defer ks.mu.Unlock()
+// KeyOptions holds the restrictions, scopes and billing a key is created with
+type KeyOptions struct {
+	AllowedIPs     []string
+	AllowedOrigins []string
+	Scopes         []string
+	Org            string
+	Plan           string // Validated by the caller against the billing catalog
+}
+
+// GenerateKey creates a new API key. Options are applied before the key is
+// stored, so it is never valid without its restrictions.
+func (ks *KeyStore) GenerateKey(name string, rateLimit int, opts KeyOptions) (*models.APIKey, error) {
+	cidrs, err := NormalizeCIDRs(opts.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+	origins, err := NormalizeOrigins(opts.AllowedOrigins)
+	if err != nil {
+		return nil, err
+	}
+	scopes, err := NormalizeScopes(opts.Scopes)
+	if err != nil {
+		return nil, err
+	}
 
 	key := generateRandomKey()
 	apiKey := &models.APIKey{
-		Key:           key,
-		Name:          name,
-		CreatedAt:     time.Now(),
-		RateLimit:     rateLimit,
-		RequestCount:  0,
-		IsActive:      true,
-		EndpointUsage: make(map[string]int64),
+		ID:             keyID(key),
+		Key:            key,
+		SigningSecret:  generateSigningSecret(),
+		Name:           name,
+		CreatedAt:      time.Now(),
+		RateLimit:      rateLimit,
+		IsActive:       true,
+		Scopes:         scopes,
+		Org:            opts.Org,
+		Plan:           opts.Plan,
+		AllowedIPs:     cidrs,
+		AllowedOrigins: origins,
 	}
 
-	ks.keys[key] = apiKey
-	return apiKey
+	if err := ks.persist(apiKey); err != nil {
+		return nil, err
+	}
+	ks.install(apiKey)
+
+	ks.audit.Record(ActorAdmin, "key_created", apiKey.ID,
+		fmt.Sprintf("name=%q allowed_ips=%v allowed_origins=%v scopes=%v org=%q plan=%q",
+			name, cidrs, origins, scopes, opts.Org, opts.Plan))
+	return apiKey, nil
 }
 
 // ValidateKey checks if a key exists and is active
 func (ks *KeyStore) ValidateKey(key string) (*models.APIKey, bool) {
-	ks.mu.RLock()
-	defer ks.mu.RUnlock()
-
-	apiKey, exists := ks.keys[key]
+	apiKey, exists := ks.find(key)
 	if !exists || !apiKey.IsActive {
 		return nil, false
 	}
@@ -63,18 +136,54 @@ func (ks *KeyStore) ValidateKey(key string) (*models.APIKey, bool) {
 	return apiKey, true
 }
 
+// ValidateKeyID checks if a key ID exists and is active
+func (ks *KeyStore) ValidateKeyID(id string) (*models.APIKey, bool) {
+	apiKey, exists := ks.find(id)
+	if !exists || apiKey.ID != id || !apiKey.IsActive {
+		return nil, false
+	}
+
+	return apiKey, true
+}
+
 // RevokeKey deactivates an API key
-func (ks *KeyStore) RevokeKey(key string) bool {
-	ks.mu.Lock()
-	defer ks.mu.Unlock()
+func (ks *KeyStore) RevokeKey(key string) error {
+	apiKey, err := ks.modify(key, func(apiKey *models.APIKey) error {
+		apiKey.IsActive = false
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-	apiKey, exists := ks.keys[key]
-	if !exists {
-		return false
+	ks.audit.Record(ActorAdmin, "key_revoked", apiKey.ID, "")
+	return nil
+}
+
+// SetKeyRestrictions replaces the IP allowlist and allowed origins for a key
+func (ks *KeyStore) SetKeyRestrictions(key string, allowedIPs, allowedOrigins []string) error {
+	cidrs, err := NormalizeCIDRs(allowedIPs)
+	if err != nil {
+		return err
 	}
 
-	apiKey.IsActive = false
-	return true
+	origins, err := NormalizeOrigins(allowedOrigins)
+	if err != nil {
+		return err
+	}
+
+	apiKey, err := ks.modify(key, func(apiKey *models.APIKey) error {
+		apiKey.AllowedIPs = cidrs
+		apiKey.AllowedOrigins = origins
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ks.audit.Record(ActorAdmin, "restrictions_updated", apiKey.ID,
+		fmt.Sprintf("allowed_ips=%v allowed_origins=%v", cidrs, origins))
+	return nil
 }
 
 // ListKeys returns all API keys
@@ -90,65 +199,180 @@ func (ks *KeyStore) ListKeys() []*models.APIKey {
 	return keys
 }
 
-// UpdateLastUsed updates the last used timestamp for a key
+// SetKeyScopes replaces the scopes granted to a key
+func (ks *KeyStore) SetKeyScopes(key string, scopes []string) error {
+	normalized, err := NormalizeScopes(scopes)
+	if err != nil {
+		return err
+	}
+
+	apiKey, err := ks.modify(key, func(apiKey *models.APIKey) error {
+		apiKey.Scopes = normalized
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ks.audit.Record(ActorAdmin, "scopes_updated", apiKey.ID, fmt.Sprintf("scopes=%v", normalized))
+	return nil
+}
+
+// SetKeyBilling assigns the organization and plan a key is billed under.
+// Plan names are validated by the caller against the billing catalog.
+func (ks *KeyStore) SetKeyBilling(key string, org, plan string) error {
+	apiKey, err := ks.modify(key, func(apiKey *models.APIKey) error {
+		apiKey.Org = org
+		apiKey.Plan = plan
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ks.audit.Record(ActorAdmin, "billing_updated", apiKey.ID, fmt.Sprintf("org=%q plan=%q", org, plan))
+	return nil
+}
+
+// ApplyEnforcement throttles or suspends a key. It is a no-op when an equal or
+// stronger enforcement is already active. Returns true if the key changed.
+func (ks *KeyStore) ApplyEnforcement(keyOrID string, enforcement *models.KeyEnforcement, actor string) bool {
+	apiKey, err := ks.modify(keyOrID, func(apiKey *models.APIKey) error {
+		current := apiKey.Enforcement
+		if current.Active(time.Now()) &&
+			(current.Action == models.EnforcementSuspended || enforcement.Action == models.EnforcementThrottled) {
+			return errUnchanged
+		}
+
+		apiKey.Enforcement = enforcement
+		return nil
+	})
+	if err != nil {
+		return false
+	}
+
+	ks.audit.Record(actor, "key_"+enforcement.Action, apiKey.ID,
+		fmt.Sprintf("%s (until %s)", enforcement.Reason, enforcement.Until.UTC().Format(time.RFC3339)))
+	return true
+}
+
+// ClearEnforcement lifts an active throttle or suspension
+func (ks *KeyStore) ClearEnforcement(keyOrID string, actor string) error {
+	var cleared *models.KeyEnforcement
+	apiKey, err := ks.modify(keyOrID, func(apiKey *models.APIKey) error {
+		if apiKey.Enforcement == nil {
+			return errUnchanged
+		}
+
+		cleared = apiKey.Enforcement
+		apiKey.Enforcement = nil
+		return nil
+	})
+	if err == errUnchanged {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if cleared.Active(time.Now()) {
+		ks.audit.Record(actor, "enforcement_cleared", apiKey.ID, cleared.Reason)
+	}
+	return nil
+}
+
+// ActiveEnforcement returns the key's throttle or suspension if still in effect
+func (ks *KeyStore) ActiveEnforcement(keyOrID string) *models.KeyEnforcement {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	apiKey, exists := ks.lookup(keyOrID)
+	if !exists || !apiKey.Enforcement.Active(time.Now()) {
+		return nil
+	}
+
+	return apiKey.Enforcement
+}
+
+// EffectiveRateLimit returns the limit to enforce, lowered while a key is throttled
+func (ks *KeyStore) EffectiveRateLimit(keyOrID string, limit int) int {
+	enforcement := ks.ActiveEnforcement(keyOrID)
+	if enforcement != nil && enforcement.Action == models.EnforcementThrottled && enforcement.RateLimit < limit {
+		return enforcement.RateLimit
+	}
+	return limit
+}
+
+// Audit returns the key management audit log
+func (ks *KeyStore) Audit() *AuditLog {
+	return ks.audit
+}
+
+// Field prefixes of the per-key usage hash in the counter store
+const (
+	usageRequests        = "requests"
+	usageEndpointPrefix  = "endpoint:"
+	usageRejectionPrefix = "rejection:"
+)
+
+// UpdateLastUsed updates the last used timestamp and request count for a key.
+// Tracking methods accept either the key or its ID, since requests
+// authenticated with access tokens only know the ID.
 func (ks *KeyStore) UpdateLastUsed(key string) {
 	ks.mu.Lock()
-	defer ks.mu.Unlock()
-
-	if apiKey, exists := ks.keys[key]; exists {
+	apiKey, exists := ks.lookup(key)
+	if exists {
 		now := time.Now()
 		apiKey.LastUsedAt = &now
-		apiKey.RequestCount++
+	}
+	ks.mu.Unlock()
+
+	if exists {
+		ks.counters.HIncr(usageKey(apiKey.ID), usageRequests, 1)
 	}
 }
 
 // TrackEndpointUsage increments usage counter for a specific endpoint
 func (ks *KeyStore) TrackEndpointUsage(key string, endpoint string) {
-	ks.mu.Lock()
-	defer ks.mu.Unlock()
+	if id, exists := ks.resolveID(key); exists {
+		ks.counters.HIncr(usageKey(id), usageEndpointPrefix+endpoint, 1)
+	}
+}
 
-	if apiKey, exists := ks.keys[key]; exists {
-		apiKey.EndpointUsage[endpoint]++
+// TrackRejection increments the rejected request counter for a key
+func (ks *KeyStore) TrackRejection(key string, reason string) {
+	if id, exists := ks.resolveID(key); exists {
+		ks.counters.HIncr(usageKey(id), usageRejectionPrefix+reason, 1)
 	}
 }
 
-// CheckRateLimit checks if the key has exceeded rate limit
+// CheckRateLimit checks if the key has exceeded rate limit. Windows are kept
+// in the counter store so the limit holds across replicas.
 func (ks *KeyStore) CheckRateLimit(key string, limit int) bool {
-	ks.mu.Lock()
-	defer ks.mu.Unlock()
-
-	now := time.Now()
-	info, exists := ks.rateLimit[key]
-
-	// If no record or window expired, create new window
-	if !exists || now.Sub(info.WindowStart) >= time.Minute {
-		ks.rateLimit[key] = &models.RateLimitInfo{
-			WindowStart: now,
-			Count:       1,
-		}
-		return true
+	// Share one window between a key and the tokens issued for it
+	id, exists := ks.resolveID(key)
+	if !exists {
+		id = key
 	}
 
-	// Check if within limit
-	if info.Count >= limit {
-		return false
+	count, err := ks.counters.IncrWindow("ratelimit:"+id, time.Minute)
+	if err != nil {
+		// Fail open rather than rejecting every request
+		return true
 	}
 
-	// Increment counter
-	info.Count++
-	return true
+	return count <= int64(limit)
 }
 
 // GetUsageStats returns aggregated usage statistics
 func (ks *KeyStore) GetUsageStats() *models.UsageStats {
 	ks.mu.RLock()
-	defer ks.mu.RUnlock()
-
 	stats := &models.UsageStats{
-		TotalKeys:     len(ks.keys),
-		ActiveKeys:    0,
-		TotalRequests: 0,
-		KeyStats:      make(map[string]*models.KeyUsageStats),
+		TotalKeys:      len(ks.keys),
+		ActiveKeys:     0,
+		TotalRequests:  0,
+		KeyStats:       make(map[string]*models.KeyUsageStats),
+		CounterBackend: ks.counters.Backend(),
 	}
 
 	for key, apiKey := range ks.keys {
@@ -156,24 +380,247 @@ func (ks *KeyStore) GetUsageStats() *models.UsageStats {
 			stats.ActiveKeys++
 		}
 
-		stats.TotalRequests += apiKey.RequestCount
+		stats.KeyStats[key] = newKeyUsageStats(apiKey)
+	}
+	ks.mu.RUnlock()
 
-		stats.KeyStats[key] = &models.KeyUsageStats{
-			Name:          apiKey.Name,
-			RequestCount:  apiKey.RequestCount,
-			LastUsedAt:    apiKey.LastUsedAt,
-			RateLimit:     apiKey.RateLimit,
-			EndpointUsage: apiKey.EndpointUsage,
-			CreatedAt:     apiKey.CreatedAt,
+	// Read counters without holding the lock, the store may be remote
+	for _, keyStats := range stats.KeyStats {
+		ks.loadUsageCounters(keyStats)
+
+		stats.TotalRequests += keyStats.RequestCount
+		for _, count := range keyStats.Rejections {
+			stats.TotalRejections += count
 		}
 	}
 
 	return stats
 }
 
+// GetKeyUsageStats returns usage statistics for a single key or key ID
+func (ks *KeyStore) GetKeyUsageStats(key string) (*models.KeyUsageStats, bool) {
+	ks.mu.RLock()
+	apiKey, exists := ks.lookup(key)
+	if !exists {
+		ks.mu.RUnlock()
+		return nil, false
+	}
+
+	keyStats := newKeyUsageStats(apiKey)
+	ks.mu.RUnlock()
+
+	ks.loadUsageCounters(keyStats)
+	return keyStats, true
+}
+
+// loadUsageCounters fills request, endpoint and rejection counts from the counter store
+func (ks *KeyStore) loadUsageCounters(keyStats *models.KeyUsageStats) {
+	usage, err := ks.counters.HGetAll(usageKey(keyStats.ID))
+	if err != nil {
+		return
+	}
+
+	for field, count := range usage {
+		switch {
+		case field == usageRequests:
+			keyStats.RequestCount = count
+		case strings.HasPrefix(field, usageEndpointPrefix):
+			keyStats.EndpointUsage[strings.TrimPrefix(field, usageEndpointPrefix)] = count
+		case strings.HasPrefix(field, usageRejectionPrefix):
+			keyStats.Rejections[strings.TrimPrefix(field, usageRejectionPrefix)] = count
+		}
+	}
+}
+
+// resolveID returns the ID of a key or key ID
+func (ks *KeyStore) resolveID(keyOrID string) (string, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	apiKey, exists := ks.lookup(keyOrID)
+	if !exists {
+		return "", false
+	}
+	return apiKey.ID, true
+}
+
+// lookup resolves a key or key ID in memory (caller must hold the lock)
+func (ks *KeyStore) lookup(keyOrID string) (*models.APIKey, bool) {
+	if apiKey, exists := ks.keys[keyOrID]; exists {
+		return apiKey, true
+	}
+
+	if key, exists := ks.ids[keyOrID]; exists {
+		return ks.keys[key], true
+	}
+
+	return nil, false
+}
+
+// find resolves a key or key ID, falling back to the registry for keys
+// created by other replicas since the last sync
+func (ks *KeyStore) find(keyOrID string) (*models.APIKey, bool) {
+	ks.mu.RLock()
+	apiKey, exists := ks.lookup(keyOrID)
+	ks.mu.RUnlock()
+
+	if exists || ks.registry == nil {
+		return apiKey, exists
+	}
+
+	id := keyOrID
+	if strings.HasPrefix(keyOrID, keyPrefix) {
+		id = keyID(keyOrID)
+	}
+
+	apiKey, err := ks.registry.Get(id)
+	if err != nil || apiKey == nil {
+		return nil, false
+	}
+	if apiKey.Key != keyOrID && apiKey.ID != keyOrID {
+		return nil, false
+	}
+
+	ks.install(apiKey)
+	return apiKey, true
+}
+
+// modify applies change to the latest copy of a key, stores it in the
+// registry and replaces the cached key. Readers hold the old copy without
+// locking, so keys are never changed in place.
+func (ks *KeyStore) modify(keyOrID string, change func(apiKey *models.APIKey) error) (*models.APIKey, error) {
+	ks.writeMu.Lock()
+	defer ks.writeMu.Unlock()
+
+	current, exists := ks.find(keyOrID)
+	if !exists {
+		return nil, ErrKeyNotFound
+	}
+
+	// Start from the registry copy, another replica may have changed the key
+	if ks.registry != nil {
+		stored, err := ks.registry.Get(current.ID)
+		if err != nil {
+			log.Printf("Failed to read API key %s from %s registry: %v", current.ID, ks.registry.Backend(), err)
+			return nil, ErrRegistryUnavailable
+		}
+		if stored != nil {
+			current = stored
+		}
+	}
+
+	updated := *current
+	if err := change(&updated); err != nil {
+		return nil, err
+	}
+
+	if err := ks.persist(&updated); err != nil {
+		return nil, err
+	}
+	ks.install(&updated)
+	return &updated, nil
+}
+
+// persist stores a key in the registry, if there is one
+func (ks *KeyStore) persist(apiKey *models.APIKey) error {
+	if ks.registry == nil {
+		return nil
+	}
+
+	if err := ks.registry.Put(apiKey); err != nil {
+		log.Printf("Failed to store API key %s in %s registry: %v", apiKey.ID, ks.registry.Backend(), err)
+		return ErrRegistryUnavailable
+	}
+	return nil
+}
+
+// install caches a key, keeping the last use seen by this replica
+func (ks *KeyStore) install(apiKey *models.APIKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if cached, exists := ks.keys[apiKey.Key]; exists && cached.LastUsedAt != nil {
+		apiKey.LastUsedAt = cached.LastUsedAt
+	}
+
+	ks.keys[apiKey.Key] = apiKey
+	ks.ids[apiKey.ID] = apiKey.Key
+}
+
+// sync replaces cached keys with the registry's
+func (ks *KeyStore) sync() error {
+	keys, err := ks.registry.List()
+	if err != nil {
+		return err
+	}
+
+	for _, apiKey := range keys {
+		ks.install(apiKey)
+	}
+	return nil
+}
+
+// syncLoop picks up keys created or changed by other replicas
+func (ks *KeyStore) syncLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ks.sync(); err != nil {
+			log.Printf("Failed to sync API keys from %s registry: %v", ks.registry.Backend(), err)
+		}
+	}
+}
+
+// claimDefaultKey reports whether this process should create the default
+// key. With a registry only the first replica to start does.
+func (ks *KeyStore) claimDefaultKey() bool {
+	if ks.registry == nil {
+		return true
+	}
+
+	claimed, err := ks.counters.SetNX(defaultKeyClaim, defaultKeyClaimTTL)
+	return err == nil && claimed
+}
+
+// newKeyUsageStats builds the usage view of a key without counters (caller must hold the lock)
+func newKeyUsageStats(apiKey *models.APIKey) *models.KeyUsageStats {
+	return &models.KeyUsageStats{
+		ID:            apiKey.ID,
+		Name:          apiKey.Name,
+		LastUsedAt:    apiKey.LastUsedAt,
+		RateLimit:     apiKey.RateLimit,
+		EndpointUsage: make(map[string]int64),
+		Rejections:    make(map[string]int64),
+		CreatedAt:     apiKey.CreatedAt,
+	}
+}
+
+// keyPrefix starts every API key
+const keyPrefix = "og_"
+
+// usageKey is the counter store key of a key's usage hash
+func usageKey(id string) string {
+	return "usage:" + id
+}
+
 // generateRandomKey creates a cryptographically secure random API key
 func generateRandomKey() string {
 	bytes := make([]byte, 32)
 	rand.Read(bytes)
-	return "og_" + hex.EncodeToString(bytes)
+	return keyPrefix + hex.EncodeToString(bytes)
+}
+
+// keyID derives the public identifier of a key. It is stable across
+// processes, so counters keyed by it are shared between replicas.
+func keyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key_" + hex.EncodeToString(sum[:8])
+}
+
+// generateSigningSecret creates the per-key secret used for request signing
+func generateSigningSecret() string {
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+	return "ogs_" + hex.EncodeToString(bytes)
 }
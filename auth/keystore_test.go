@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daiwikmh/origami/counters"
+	"github.com/daiwikmh/origami/resp"
+	"github.com/daiwikmh/origami/resp/resptest"
+)
+
+// newReplicas starts n key stores sharing one in-process Redis-protocol server
+func newReplicas(t *testing.T, n int) []*KeyStore {
+	t.Helper()
+
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	replicas := make([]*KeyStore, n)
+	for i := range replicas {
+		client := resp.NewClient(server.Addr, "", 2, time.Second)
+		t.Cleanup(client.Close)
+		replicas[i] = NewKeyStore(counters.NewRedisStore(client), NewRedisKeyRegistry(client), time.Hour)
+	}
+	return replicas
+}
+
+func TestKeyStoreSharesKeysAcrossReplicas(t *testing.T) {
+	replicas := newReplicas(t, 2)
+
+	// Only the first replica creates the default key, the second loads it
+	first, second := replicas[0].ListKeys(), replicas[1].ListKeys()
+	if len(first) != 1 || len(second) != 1 || first[0].ID != second[0].ID {
+		t.Fatalf("default keys = %d and %d, want one shared key", len(first), len(second))
+	}
+
+	apiKey, err := replicas[0].GenerateKey("shared", 3, KeyOptions{Scopes: []string{"markets:read"}})
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	// A key created on one replica is accepted by another before the next sync
+	found, valid := replicas[1].ValidateKey(apiKey.Key)
+	if !valid {
+		t.Fatal("key created on another replica was rejected")
+	}
+	if found.ID != apiKey.ID || found.SigningSecret != apiKey.SigningSecret || len(found.Scopes) != 1 {
+		t.Errorf("loaded key = %+v, want %+v", found, apiKey)
+	}
+	if _, valid := replicas[1].ValidateKeyID(apiKey.ID); !valid {
+		t.Error("key ID created on another replica was rejected")
+	}
+
+	// Rate limits count against one window keyed by the shared ID
+	for i := 0; i < 3; i++ {
+		if !replicas[i%2].CheckRateLimit(apiKey.Key, apiKey.RateLimit) {
+			t.Fatalf("request %d rejected within the limit", i+1)
+		}
+	}
+	if replicas[1].CheckRateLimit(apiKey.Key, apiKey.RateLimit) {
+		t.Error("request over the limit accepted across replicas")
+	}
+
+	// Revocation reaches other replicas on their next sync
+	if err := replicas[0].RevokeKey(apiKey.Key); err != nil {
+		t.Fatalf("RevokeKey: %v", err)
+	}
+	if err := replicas[1].sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if _, valid := replicas[1].ValidateKey(apiKey.Key); valid {
+		t.Error("key revoked on another replica is still accepted")
+	}
+}
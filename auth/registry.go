@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/daiwikmh/origami/models"
+	"github.com/daiwikmh/origami/resp"
+)
+
+// KeyRegistry persists API keys so every replica serves the same keys
+type KeyRegistry interface {
+	// Get returns the key with the given ID, or nil if there is none
+	Get(id string) (*models.APIKey, error)
+
+	// Put stores a key, replacing any key with the same ID
+	Put(apiKey *models.APIKey) error
+
+	// List returns every stored key
+	List() ([]*models.APIKey, error)
+
+	// Backend describes the registry for diagnostics
+	Backend() string
+}
+
+const registryKey = "origami:apikeys"
+
+// RedisKeyRegistry keeps API keys in a hash of a Redis-protocol server,
+// one JSON record per key ID
+type RedisKeyRegistry struct {
+	client *resp.Client
+}
+
+// NewRedisKeyRegistry creates a registry backed by the given client
+func NewRedisKeyRegistry(client *resp.Client) *RedisKeyRegistry {
+	return &RedisKeyRegistry{client: client}
+}
+
+// keyRecord is the stored form of a key, including its signing secret
+type keyRecord struct {
+	*models.APIKey
+	SigningSecret string `json:"signing_secret"`
+}
+
+// Get returns the key with the given ID
+func (rr *RedisKeyRegistry) Get(id string) (*models.APIKey, error) {
+	reply, err := rr.client.Do("HGET", registryKey, id)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+
+	raw, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("auth: unexpected HGET reply %T", reply)
+	}
+	return decodeKey(raw)
+}
+
+// Put stores a key
+func (rr *RedisKeyRegistry) Put(apiKey *models.APIKey) error {
+	data, err := json.Marshal(keyRecord{APIKey: apiKey, SigningSecret: apiKey.SigningSecret})
+	if err != nil {
+		return err
+	}
+
+	_, err = rr.client.Do("HSET", registryKey, apiKey.ID, string(data))
+	return err
+}
+
+// List returns every stored key
+func (rr *RedisKeyRegistry) List() ([]*models.APIKey, error) {
+	reply, err := rr.client.Do("HGETALL", registryKey)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("auth: unexpected HGETALL reply %T", reply)
+	}
+
+	keys := make([]*models.APIKey, 0, len(items)/2)
+	for i := 1; i < len(items); i += 2 {
+		raw, _ := items[i].(string)
+		apiKey, err := decodeKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, apiKey)
+	}
+
+	return keys, nil
+}
+
+// Backend identifies the registry
+func (rr *RedisKeyRegistry) Backend() string {
+	return "redis"
+}
+
+// decodeKey parses a stored key record
+func decodeKey(raw string) (*models.APIKey, error) {
+	record := keyRecord{APIKey: &models.APIKey{}}
+	if err := json.Unmarshal([]byte(raw), &record); err != nil {
+		return nil, fmt.Errorf("auth: invalid key record: %w", err)
+	}
+
+	record.APIKey.SigningSecret = record.SigningSecret
+	return record.APIKey, nil
+}
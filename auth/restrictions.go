@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// Rejection reasons reported in usage statistics
+const (
+	RejectIPNotAllowed     = "ip_not_allowed"
+	RejectOriginNotAllowed = "origin_not_allowed"
+	RejectOriginMissing    = "origin_missing"
+	RejectKeySuspended     = "key_suspended"
+)
+
+// NormalizeCIDRs validates allowlist entries and converts single IPs to host CIDRs
+func NormalizeCIDRs(entries []string) ([]string, error) {
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address: %s", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr: %s", entry)
+		}
+
+		result = append(result, network.String())
+	}
+
+	return result, nil
+}
+
+// NormalizeOrigins validates origin patterns such as "https://app.example.com",
+// "https://*.example.com" or "*"
+func NormalizeOrigins(patterns []string) ([]string, error) {
+	result := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimRight(strings.TrimSpace(pattern), "/"))
+		if pattern == "" {
+			continue
+		}
+
+		if pattern != "*" {
+			scheme, host, ok := strings.Cut(pattern, "://")
+			if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "/?#") {
+				return nil, fmt.Errorf("invalid origin pattern: %s", pattern)
+			}
+			if strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+				return nil, fmt.Errorf("wildcard only allowed as leading subdomain: %s", pattern)
+			}
+		}
+
+		result = append(result, pattern)
+	}
+
+	return result, nil
+}
+
+// IPAllowed reports whether the client IP satisfies the key's allowlist
+func IPAllowed(key *models.APIKey, clientIP string) bool {
+	if len(key.AllowedIPs) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range key.AllowedIPs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OriginAllowed reports whether an origin (scheme://host[:port]) matches any pattern.
+// An empty pattern list allows every origin.
+func OriginAllowed(patterns []string, origin string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	origin = strings.ToLower(strings.TrimRight(origin, "/"))
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+
+		patternScheme, patternHost, _ := strings.Cut(pattern, "://")
+		if patternScheme != scheme || !strings.HasPrefix(patternHost, "*.") {
+			continue
+		}
+
+		// "*.example.com" matches subdomains but not the apex domain
+		if strings.HasSuffix(host, patternHost[1:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequestOrigin derives the browser origin from the Origin header, falling back to Referer
+func RequestOrigin(origin, referer string) string {
+	if origin != "" && origin != "null" {
+		return origin
+	}
+
+	if referer == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(referer)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return ""
+	}
+
+	return parsed.Scheme + "://" + parsed.Host
+}
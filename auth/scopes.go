@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// Scopes that can be granted to an API key. A key without scopes has full access.
+const (
+	ScopeMarkets = "markets:read"
+	ScopeSignals = "signals:read"
+	ScopeNFT     = "nft:read"
+)
+
+var knownScopes = map[string]bool{
+	ScopeMarkets: true,
+	ScopeSignals: true,
+	ScopeNFT:     true,
+}
+
+// NormalizeScopes validates scope names and removes duplicates
+func NormalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !knownScopes[scope] {
+			return nil, fmt.Errorf("unknown scope: %s", scope)
+		}
+		seen[scope] = true
+		result = append(result, scope)
+	}
+	return result, nil
+}
+
+// ScopeForPath maps an /origami route to the scope it requires.
+// Returns an empty string for routes every key may call.
+func ScopeForPath(path string) string {
+	path = strings.TrimPrefix(path, "/origami/")
+	section, _, _ := strings.Cut(path, "/")
+
+	switch section {
+	case "markets":
+		return ScopeMarkets
+	case "signals":
+		return ScopeSignals
+	case "nft":
+		return ScopeNFT
+	default:
+		return ""
+	}
+}
+
+// HasScope reports whether the key grants the scope
+func HasScope(key *models.APIKey, scope string) bool {
+	if scope == "" || len(key.Scopes) == 0 {
+		return true
+	}
+
+	for _, granted := range key.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
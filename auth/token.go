@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+const tokenIssuer = "origami"
+
+// Token verification errors
+var (
+	ErrTokenMalformed = errors.New("malformed access token")
+	ErrTokenSignature = errors.New("invalid access token signature")
+	ErrTokenExpired   = errors.New("access token expired")
+)
+
+// AccessClaims is the payload of an access token. It carries everything the
+// middleware needs so tokens can be verified without a KeyStore lookup.
+type AccessClaims struct {
+	Subject        string   `json:"sub"` // API key ID
+	Name           string   `json:"name"`
+	Scopes         []string `json:"scopes,omitempty"`
+	RateLimit      int      `json:"rate_limit"`
+	AllowedIPs     []string `json:"allowed_ips,omitempty"`
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	Issuer         string   `json:"iss"`
+	IssuedAt       int64    `json:"iat"`
+	ExpiresAt      int64    `json:"exp"`
+	TokenID        string   `json:"jti"`
+}
+
+// APIKey builds a detached key object from the claims for downstream middleware
+func (claims *AccessClaims) APIKey() *models.APIKey {
+	return &models.APIKey{
+		ID:             claims.Subject,
+		Name:           claims.Name,
+		Scopes:         claims.Scopes,
+		RateLimit:      claims.RateLimit,
+		AllowedIPs:     claims.AllowedIPs,
+		AllowedOrigins: claims.AllowedOrigins,
+		IsActive:       true,
+	}
+}
+
+// TokenIssuer signs and verifies HS256 JWT access tokens
+type TokenIssuer struct {
+	secret     []byte
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// NewTokenIssuer creates an issuer. An empty secret generates a random one,
+// which invalidates tokens on restart and across replicas.
+func NewTokenIssuer(secret string, defaultTTL, maxTTL time.Duration) *TokenIssuer {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+
+	return &TokenIssuer{
+		secret:     key,
+		defaultTTL: defaultTTL,
+		maxTTL:     maxTTL,
+	}
+}
+
+// MaxTTL returns the longest lifetime a token may be issued for
+func (ti *TokenIssuer) MaxTTL() time.Duration {
+	return ti.maxTTL
+}
+
+// Issue signs an access token for the key. A zero ttl uses the default and
+// longer requests are capped at the maximum.
+func (ti *TokenIssuer) Issue(key *models.APIKey, ttl time.Duration) (string, *AccessClaims, error) {
+	if ttl <= 0 {
+		ttl = ti.defaultTTL
+	}
+	if ttl > ti.maxTTL {
+		ttl = ti.maxTTL
+	}
+
+	jti := make([]byte, 12)
+	rand.Read(jti)
+
+	now := time.Now()
+	claims := &AccessClaims{
+		Subject:        key.ID,
+		Name:           key.Name,
+		Scopes:         key.Scopes,
+		RateLimit:      key.RateLimit,
+		AllowedIPs:     key.AllowedIPs,
+		AllowedOrigins: key.AllowedOrigins,
+		Issuer:         tokenIssuer,
+		IssuedAt:       now.Unix(),
+		ExpiresAt:      now.Add(ttl).Unix(),
+		TokenID:        hex.EncodeToString(jti),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	return signingInput + "." + ti.sign(signingInput), claims, nil
+}
+
+// Verify checks the signature and expiry of a token and returns its claims
+func (ti *TokenIssuer) Verify(token string) (*AccessClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	// Only accept the algorithm we issue; never trust "none" or others
+	if header.Alg != "HS256" {
+		return nil, ErrTokenSignature
+	}
+
+	expected := ti.sign(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrTokenSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	var claims AccessClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	if claims.Issuer != tokenIssuer || claims.Subject == "" {
+		return nil, ErrTokenMalformed
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+// sign computes the base64url HMAC-SHA256 signature of the signing input
+func (ti *TokenIssuer) sign(signingInput string) string {
+	mac := hmac.New(sha256.New, ti.secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,288 @@
+package billing
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/daiwikmh/origami/counters"
+	"github.com/daiwikmh/origami/models"
+)
+
+// Statement grouping
+const (
+	GroupByKey = "key"
+	GroupByOrg = "org"
+)
+
+// periodLayout formats billing periods as YYYY-MM
+const periodLayout = "2006-01"
+
+// KeyLister provides the keys to bill
+type KeyLister interface {
+	ListKeys() []*models.APIKey
+}
+
+// Service meters weighted usage per key and month and turns it into statements
+type Service struct {
+	counters counters.Store
+	keys     KeyLister
+}
+
+// NewService creates a billing service. Usage is metered in the counter store,
+// so statements cover every replica sharing it.
+func NewService(counterStore counters.Store, keys KeyLister) *Service {
+	return &Service{
+		counters: counterStore,
+		keys:     keys,
+	}
+}
+
+// Record meters one request of a key to an endpoint in the current period
+func (s *Service) Record(keyID, endpoint string) {
+	s.counters.HIncr(meterKey(Period(time.Now()), keyID), endpoint, 1)
+}
+
+// Period returns the billing period containing t
+func Period(t time.Time) string {
+	return t.UTC().Format(periodLayout)
+}
+
+// ParsePeriod validates a YYYY-MM period and returns its bounds.
+// "current" selects the period containing now.
+func ParsePeriod(period string) (string, time.Time, time.Time, error) {
+	if period == "current" {
+		period = Period(time.Now())
+	}
+
+	start, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("invalid period %q, expected YYYY-MM", period)
+	}
+
+	return period, start, start.AddDate(0, 1, 0), nil
+}
+
+// Statements builds the statements of every account for a period
+func (s *Service) Statements(period, groupBy string) ([]*models.Statement, error) {
+	period, start, end, err := ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make(map[string]*account)
+	order := make([]string, 0)
+
+	for _, key := range s.keys.ListKeys() {
+		// Keys created after the period have nothing to bill
+		if !key.CreatedAt.Before(end) {
+			continue
+		}
+
+		accountType, accountID, accountName := GroupByKey, key.ID, key.Name
+		if groupBy == GroupByOrg && key.Org != "" {
+			accountType, accountID, accountName = GroupByOrg, key.Org, key.Org
+		}
+
+		acc, exists := accounts[accountID]
+		if !exists {
+			acc = &account{accountType: accountType, id: accountID, name: accountName}
+			accounts[accountID] = acc
+			order = append(order, accountID)
+		}
+		acc.keys = append(acc.keys, key)
+	}
+
+	sort.Strings(order)
+
+	statements := make([]*models.Statement, 0, len(order))
+	for _, id := range order {
+		statements = append(statements, s.statement(accounts[id], period, start, end))
+	}
+
+	return statements, nil
+}
+
+// Estimate returns the current-period statement of the account a key is billed
+// to: its organization if it has one, otherwise the key itself
+func (s *Service) Estimate(key *models.APIKey) *models.Statement {
+	period, start, end, _ := ParsePeriod("current")
+
+	acc := &account{accountType: GroupByKey, id: key.ID, name: key.Name}
+	if key.Org == "" {
+		acc.keys = []*models.APIKey{key}
+	} else {
+		acc.accountType, acc.id, acc.name = GroupByOrg, key.Org, key.Org
+		for _, candidate := range s.keys.ListKeys() {
+			if candidate.Org == key.Org {
+				acc.keys = append(acc.keys, candidate)
+			}
+		}
+	}
+
+	return s.statement(acc, period, start, end)
+}
+
+// account is a set of keys billed together
+type account struct {
+	accountType string
+	id          string
+	name        string
+	keys        []*models.APIKey
+}
+
+// plan picks the account's plan. An organization whose keys carry different
+// plans is billed under the one with the largest allowance.
+func (acc *account) plan() *models.Plan {
+	var best *models.Plan
+	for _, key := range acc.keys {
+		plan, exists := LookupPlan(key.Plan)
+		if !exists {
+			continue
+		}
+		if best == nil || plan.IncludedUnits > best.IncludedUnits {
+			best = plan
+		}
+	}
+
+	if best == nil {
+		best, _ = LookupPlan(DefaultPlan)
+	}
+	return best
+}
+
+// statement prices an account's usage for a period
+func (s *Service) statement(acc *account, period string, start, end time.Time) *models.Statement {
+	plan := acc.plan()
+	now := time.Now()
+
+	statement := &models.Statement{
+		Period:        period,
+		AccountType:   acc.accountType,
+		AccountID:     acc.id,
+		AccountName:   acc.name,
+		KeyIDs:        make([]string, 0, len(acc.keys)),
+		Plan:          plan.Name,
+		Currency:      Currency,
+		IncludedUnits: plan.IncludedUnits,
+		BaseFee:       plan.MonthlyFee,
+		Lines:         make([]*models.StatementLine, 0),
+		GeneratedAt:   now,
+		Final:         !now.Before(end),
+	}
+
+	// Merge endpoint counts of all keys in the account
+	requests := make(map[string]int64)
+	for _, key := range acc.keys {
+		statement.KeyIDs = append(statement.KeyIDs, key.ID)
+
+		usage, err := s.counters.HGetAll(meterKey(period, key.ID))
+		if err != nil {
+			continue
+		}
+		for endpoint, count := range usage {
+			requests[endpoint] += count
+		}
+	}
+	sort.Strings(statement.KeyIDs)
+
+	for endpoint, count := range requests {
+		weight := endpointWeight(endpoint)
+		line := &models.StatementLine{
+			Endpoint: endpoint,
+			Requests: count,
+			Weight:   weight,
+			Units:    float64(count) * weight,
+		}
+
+		statement.Lines = append(statement.Lines, line)
+		statement.Requests += count
+		statement.Units += line.Units
+	}
+
+	sort.Slice(statement.Lines, func(i, j int) bool {
+		return statement.Lines[i].Endpoint < statement.Lines[j].Endpoint
+	})
+
+	statement.OverageUnits, statement.OverageFee = overage(plan, statement.Units)
+	statement.Total = roundCents(statement.BaseFee + statement.OverageFee)
+
+	// Extrapolate open periods linearly to the end of the month
+	if !statement.Final && now.After(start) {
+		elapsed := now.Sub(start).Seconds() / end.Sub(start).Seconds()
+		statement.ProjectedUnits = math.Round(statement.Units / elapsed)
+		_, projectedOverage := overage(plan, statement.ProjectedUnits)
+		statement.ProjectedTotal = roundCents(statement.BaseFee + projectedOverage)
+	}
+
+	return statement
+}
+
+// overage returns the units above the plan allowance and their price
+func overage(plan *models.Plan, units float64) (float64, float64) {
+	extra := units - float64(plan.IncludedUnits)
+	if extra <= 0 {
+		return 0, 0
+	}
+	return extra, roundCents(extra / 1000 * plan.OveragePer1K)
+}
+
+// WriteCSV writes one row per statement, or one row per endpoint line when lines is true
+func WriteCSV(w io.Writer, statements []*models.Statement, lines bool) error {
+	writer := csv.NewWriter(w)
+
+	if lines {
+		writer.Write([]string{"period", "account_type", "account_id", "endpoint", "requests", "weight", "units"})
+		for _, st := range statements {
+			for _, line := range st.Lines {
+				writer.Write([]string{
+					st.Period, st.AccountType, st.AccountID, line.Endpoint,
+					strconv.FormatInt(line.Requests, 10),
+					formatFloat(line.Weight),
+					formatFloat(line.Units),
+				})
+			}
+		}
+	} else {
+		writer.Write([]string{
+			"period", "account_type", "account_id", "account_name", "plan", "requests", "units",
+			"included_units", "overage_units", "base_fee", "overage_fee", "total", "currency", "final",
+		})
+		for _, st := range statements {
+			writer.Write([]string{
+				st.Period, st.AccountType, st.AccountID, st.AccountName, st.Plan,
+				strconv.FormatInt(st.Requests, 10),
+				formatFloat(st.Units),
+				strconv.FormatInt(st.IncludedUnits, 10),
+				formatFloat(st.OverageUnits),
+				strconv.FormatFloat(st.BaseFee, 'f', 2, 64),
+				strconv.FormatFloat(st.OverageFee, 'f', 2, 64),
+				strconv.FormatFloat(st.Total, 'f', 2, 64),
+				st.Currency,
+				strconv.FormatBool(st.Final),
+			})
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// meterKey is the counter store key holding a key's usage for a period
+func meterKey(period, keyID string) string {
+	return "billing:" + period + ":" + keyID
+}
+
+// roundCents rounds an amount to two decimals
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// formatFloat formats a number without trailing zeros
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
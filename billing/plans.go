@@ -0,0 +1,70 @@
+package billing
+
+import "github.com/daiwikmh/origami/models"
+
+// DefaultPlan is used for keys without an explicit plan
+const DefaultPlan = "free"
+
+// Currency of all plan prices
+const Currency = "USD"
+
+// Plans is the pricing catalog
+var Plans = map[string]*models.Plan{
+	"free": {
+		Name:          "free",
+		MonthlyFee:    0,
+		IncludedUnits: 10_000,
+		OveragePer1K:  0,
+	},
+	"developer": {
+		Name:          "developer",
+		MonthlyFee:    29,
+		IncludedUnits: 500_000,
+		OveragePer1K:  0.10,
+	},
+	"pro": {
+		Name:          "pro",
+		MonthlyFee:    199,
+		IncludedUnits: 5_000_000,
+		OveragePer1K:  0.05,
+	},
+	"enterprise": {
+		Name:          "enterprise",
+		MonthlyFee:    999,
+		IncludedUnits: 50_000_000,
+		OveragePer1K:  0.02,
+	},
+}
+
+// EndpointWeights sets how many units a request to an endpoint costs.
+// Endpoints not listed cost one unit.
+var EndpointWeights = map[string]float64{
+	"GET /origami/markets/:id/analytics": 2,
+	"GET /origami/signals/trending":      2,
+	"GET /origami/signals/hot":           2,
+	"GET /origami/signals/volatile":      2,
+	"GET /origami/signals/volume":        2,
+	"GET /origami/nft/verify/:address":   5,
+	"POST /origami/nft/verify/batch":     25,
+	"GET /origami/me":                    0,
+	"GET /origami/me/limits":             0,
+	"POST /origami/token":                0,
+}
+
+// LookupPlan resolves a plan name, treating an empty name as the default plan
+func LookupPlan(name string) (*models.Plan, bool) {
+	if name == "" {
+		name = DefaultPlan
+	}
+
+	plan, exists := Plans[name]
+	return plan, exists
+}
+
+// endpointWeight returns the unit cost of an endpoint
+func endpointWeight(endpoint string) float64 {
+	if weight, exists := EndpointWeights[endpoint]; exists {
+		return weight
+	}
+	return 1
+}
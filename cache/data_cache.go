@@ -1,35 +1,81 @@
 package cache
 
 import (
+	"hash/fnv"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/daiwikmh/origami/models"
 )
 
+// cacheShards is the number of independently locked market maps
+const cacheShards = 32
+
 // CacheEntry holds cached data with expiration
 type CacheEntry struct {
 	Data      interface{}
+	StoredAt  time.Time
 	ExpiresAt time.Time
 }
 
-// DataCache provides thread-safe in-memory caching with TTL
+// DataCache is the in-memory MarketStore, local to one process.
+//
+// Markets are spread over shards by ID. A shard's lock only guards its map
+// and is held for writing just when a market is first stored; the data of a
+// market sits behind atomic pointers, so readers never wait for writers.
+// Orderbooks and trades count against a memory budget, and those of the least
+// recently used markets are evicted when it is exceeded. Price history is
+// kept for analytics and only reported.
 type DataCache struct {
-	markets      *CacheEntry
-	orderbooks   map[string]*CacheEntry
-	trades       map[string][]*models.Trade
-	priceHistory map[string]*models.PriceHistory
-	analytics    map[string]*models.MarketAnalytics
-	mu           sync.RWMutex
+	markets     atomic.Pointer[CacheEntry]
+	shards      [cacheShards]*cacheShard
+	staleMaxAge atomic.Int64 // How long analytics restored from a snapshot are served
+	maxBytes    int64        // Memory budget, zero for unbounded
+	bytes       atomic.Int64 // Estimated size of budgeted data
+	history     atomic.Int64 // Estimated size of price history, outside the budget
+	evictions   atomic.Int64
+	evicting    atomic.Bool
+}
+
+// cacheShard holds the markets whose ID hashes to it
+type cacheShard struct {
+	entries map[string]*marketEntry
+	mu      sync.RWMutex
+}
+
+// marketEntry holds everything cached for one market. Values behind the
+// pointers are never modified once stored.
+type marketEntry struct {
+	orderbook    atomic.Pointer[CacheEntry]
+	trades       atomic.Pointer[[]*models.Trade]
+	priceHistory atomic.Pointer[models.PriceHistory]
+	analytics    atomic.Pointer[models.MarketAnalytics]
+	lastAccess   atomic.Int64 // Unix nanoseconds of the last client read, zero if never read
+
+	// Estimated sizes, changed with mu held
+	orderbookBytes atomic.Int64
+	tradesBytes    atomic.Int64
+	historyBytes   atomic.Int64
+	mu             sync.Mutex // Serializes writers
+}
+
+// CacheStats describes the size of a DataCache
+type CacheStats struct {
+	Markets      int   `json:"markets"`
+	Bytes        int64 `json:"bytes"`         // Estimated size of orderbooks and trades
+	MaxBytes     int64 `json:"max_bytes"`     // Zero when unbounded
+	HistoryBytes int64 `json:"history_bytes"` // Estimated size of price history, not budgeted
+	Evictions    int64 `json:"evictions"`     // Markets whose orderbook and trades were evicted
 }
 
-// NewDataCache initializes an empty cache
-func NewDataCache() *DataCache {
-	cache := &DataCache{
-		orderbooks:   make(map[string]*CacheEntry),
-		trades:       make(map[string][]*models.Trade),
-		priceHistory: make(map[string]*models.PriceHistory),
-		analytics:    make(map[string]*models.MarketAnalytics),
+// NewDataCache initializes an empty cache holding about maxBytes of market
+// data, unbounded when maxBytes is zero
+func NewDataCache(maxBytes int64) *DataCache {
+	cache := &DataCache{maxBytes: maxBytes}
+	for i := range cache.shards {
+		cache.shards[i] = &cacheShard{entries: make(map[string]*marketEntry)}
 	}
 
 	// Start cleanup goroutine
@@ -40,171 +86,322 @@ func NewDataCache() *DataCache {
 
 // SetMarkets stores market list with TTL
 func (c *DataCache) SetMarkets(data interface{}, ttl time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.markets = &CacheEntry{
+	c.markets.Store(&CacheEntry{
 		Data:      data,
 		ExpiresAt: time.Now().Add(ttl),
-	}
+	})
 }
 
 // GetMarkets retrieves cached markets if not expired
 func (c *DataCache) GetMarkets() (interface{}, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	if c.markets == nil || c.isExpired(c.markets) {
+	entry := c.markets.Load()
+	if entry == nil || c.isExpired(entry) {
 		return nil, false
 	}
 
-	return c.markets.Data, true
+	return entry.Data, true
 }
 
 // SetOrderbook stores orderbook for a market with TTL
 func (c *DataCache) SetOrderbook(marketID string, data interface{}, ttl time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	now := time.Now()
+	e := c.entry(marketID, true)
 
-	c.orderbooks[marketID] = &CacheEntry{
+	e.mu.Lock()
+	e.orderbook.Store(&CacheEntry{
 		Data:      data,
-		ExpiresAt: time.Now().Add(ttl),
-	}
+		StoredAt:  now,
+		ExpiresAt: now.Add(ttl),
+	})
+	c.resize(&e.orderbookBytes, estimateSize(data))
+	e.mu.Unlock()
+
+	c.enforceBudget()
 }
 
 // GetOrderbook retrieves cached orderbook if not expired
 func (c *DataCache) GetOrderbook(marketID string) (interface{}, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	entry, exists := c.orderbooks[marketID]
-	if !exists || c.isExpired(entry) {
+	entry, found := c.GetOrderbookEntry(marketID)
+	if !found {
 		return nil, false
 	}
 
 	return entry.Data, true
 }
 
+// GetOrderbookEntry retrieves cached orderbook with its store time if not expired
+func (c *DataCache) GetOrderbookEntry(marketID string) (*CacheEntry, bool) {
+	e := c.entry(marketID, false)
+	if e == nil {
+		return nil, false
+	}
+
+	entry := e.orderbook.Load()
+	if entry == nil || c.isExpired(entry) {
+		return nil, false
+	}
+
+	copied := *entry
+	return &copied, true
+}
+
 // SetTrades replaces trade history for a market
 func (c *DataCache) SetTrades(marketID string, trades []*models.Trade) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	e := c.entry(marketID, true)
+
+	e.mu.Lock()
+	e.trades.Store(&trades)
+	c.resize(&e.tradesBytes, tradesSize(trades))
+	e.mu.Unlock()
 
-	c.trades[marketID] = trades
+	c.enforceBudget()
 }
 
 // GetTrades retrieves trade history for a market
 func (c *DataCache) GetTrades(marketID string) ([]*models.Trade, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	e := c.entry(marketID, false)
+	if e == nil {
+		return nil, false
+	}
+
+	trades := e.trades.Load()
+	if trades == nil {
+		return nil, false
+	}
 
-	trades, exists := c.trades[marketID]
-	return trades, exists
+	return *trades, true
 }
 
 // AppendTrade adds a new trade and maintains rolling window (max 1000)
 func (c *DataCache) AppendTrade(marketID string, trade *models.Trade) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	e := c.entry(marketID, true)
+
+	e.mu.Lock()
+	var trades []*models.Trade
+	if current := e.trades.Load(); current != nil {
+		trades = *current
+	}
 
-	trades := c.trades[marketID]
+	// Appending only writes past the end of slices already handed to readers
 	trades = append(trades, trade)
 
 	// Keep last 1000 trades
-	if len(trades) > 1000 {
-		trades = trades[len(trades)-1000:]
+	if len(trades) > MaxTrades {
+		trades = trades[len(trades)-MaxTrades:]
 	}
 
-	c.trades[marketID] = trades
+	e.trades.Store(&trades)
+	c.resize(&e.tradesBytes, tradesSize(trades))
+	e.mu.Unlock()
+
+	c.enforceBudget()
 }
 
 // SetPriceHistory stores price history for a market
 func (c *DataCache) SetPriceHistory(marketID string, history *models.PriceHistory) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	e := c.entry(marketID, true)
 
-	c.priceHistory[marketID] = history
+	e.mu.Lock()
+	e.priceHistory.Store(history)
+	size := historySize(history)
+	c.history.Add(size - e.historyBytes.Swap(size))
+	e.mu.Unlock()
 }
 
 // GetPriceHistory retrieves price history for a market
 func (c *DataCache) GetPriceHistory(marketID string) (*models.PriceHistory, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	e := c.entry(marketID, false)
+	if e == nil {
+		return nil, false
+	}
 
-	history, exists := c.priceHistory[marketID]
-	return history, exists
+	history := e.priceHistory.Load()
+	return history, history != nil
 }
 
 // SetAnalytics stores computed analytics for a market
 func (c *DataCache) SetAnalytics(marketID string, analytics *models.MarketAnalytics) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.analytics[marketID] = analytics
+	c.entry(marketID, true).analytics.Store(analytics)
 }
 
-// GetAnalytics retrieves cached analytics for a market
+// GetAnalytics retrieves the latest cached analytics for a market
 func (c *DataCache) GetAnalytics(marketID string) (*models.MarketAnalytics, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	analytics, exists := c.analytics[marketID]
-	if !exists {
+	e := c.entry(marketID, false)
+	if e == nil {
 		return nil, false
 	}
 
-	// Check if analytics are fresh (within 15 seconds)
-	if time.Since(analytics.Timestamp) > 15*time.Second {
+	analytics := e.analytics.Load()
+	if analytics == nil || !c.retained(analytics) {
 		return nil, false
 	}
 
 	return analytics, true
 }
 
-// GetAllAnalytics retrieves all cached analytics (for trending calculations)
+// GetAllAnalytics retrieves the latest cached analytics of all markets (for trending calculations)
 func (c *DataCache) GetAllAnalytics() []*models.MarketAnalytics {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	result := make([]*models.MarketAnalytics, 0)
 
-	result := make([]*models.MarketAnalytics, 0, len(c.analytics))
-	for _, analytics := range c.analytics {
-		// Only include fresh analytics (within 15 seconds)
-		if time.Since(analytics.Timestamp) <= 15*time.Second {
+	c.forEach(func(_ string, e *marketEntry) {
+		if analytics := e.analytics.Load(); analytics != nil && c.retained(analytics) {
 			result = append(result, analytics)
 		}
-	}
+	})
 
 	return result
 }
 
-// isExpired checks if a cache entry has expired (not thread-safe, caller must lock)
+// Backend names the store implementation
+func (c *DataCache) Backend() string {
+	return "memory"
+}
+
+// Stats returns the size of the cache
+func (c *DataCache) Stats() CacheStats {
+	markets := 0
+	c.forEach(func(string, *marketEntry) { markets++ })
+
+	return CacheStats{
+		Markets:      markets,
+		Bytes:        c.bytes.Load(),
+		MaxBytes:     c.maxBytes,
+		HistoryBytes: c.history.Load(),
+		Evictions:    c.evictions.Load(),
+	}
+}
+
+// entry returns the entry of a market. Missing entries are created when
+// create is set, otherwise nil is returned.
+func (c *DataCache) entry(marketID string, create bool) *marketEntry {
+	shard := c.shard(marketID)
+
+	shard.mu.RLock()
+	e, exists := shard.entries[marketID]
+	shard.mu.RUnlock()
+
+	if !exists {
+		if !create {
+			return nil
+		}
+
+		shard.mu.Lock()
+		if e, exists = shard.entries[marketID]; !exists {
+			e = &marketEntry{}
+			shard.entries[marketID] = e
+		}
+		shard.mu.Unlock()
+	}
+
+	return e
+}
+
+// Touch records a client read of a market for eviction. Writes and internal
+// reads don't count, so markets only the collector refreshes are evicted first.
+func (c *DataCache) Touch(marketID string) {
+	if e := c.entry(marketID, false); e != nil {
+		e.lastAccess.Store(time.Now().UnixNano())
+	}
+}
+
+// shard returns the shard holding a market
+func (c *DataCache) shard(marketID string) *cacheShard {
+	h := fnv.New32a()
+	h.Write([]byte(marketID))
+	return c.shards[h.Sum32()%cacheShards]
+}
+
+// forEach calls fn for every market, holding one shard's read lock at a time
+func (c *DataCache) forEach(fn func(marketID string, e *marketEntry)) {
+	for _, shard := range c.shards {
+		shard.mu.RLock()
+		for marketID, e := range shard.entries {
+			fn(marketID, e)
+		}
+		shard.mu.RUnlock()
+	}
+}
+
+// resize records the new estimated size of a budgeted value (caller must hold the entry's mu)
+func (c *DataCache) resize(size *atomic.Int64, bytes int64) {
+	c.bytes.Add(bytes - size.Swap(bytes))
+}
+
+// enforceBudget evicts the orderbooks and trades of the least recently used
+// markets until the cache is back under 90% of its budget. Only one caller
+// evicts at a time, others return immediately.
+func (c *DataCache) enforceBudget() {
+	if c.maxBytes <= 0 || c.bytes.Load() <= c.maxBytes || !c.evicting.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.evicting.Store(false)
+
+	type candidate struct {
+		entry      *marketEntry
+		lastAccess int64
+	}
+
+	candidates := make([]candidate, 0)
+	c.forEach(func(_ string, e *marketEntry) {
+		if e.orderbookBytes.Load()+e.tradesBytes.Load() > 0 {
+			candidates = append(candidates, candidate{e, e.lastAccess.Load()})
+		}
+	})
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastAccess < candidates[j].lastAccess
+	})
+
+	target := c.maxBytes / 10 * 9
+	for _, cand := range candidates {
+		if c.bytes.Load() <= target {
+			break
+		}
+
+		e := cand.entry
+		e.mu.Lock()
+		e.orderbook.Store(nil)
+		e.trades.Store(nil)
+		c.resize(&e.orderbookBytes, 0)
+		c.resize(&e.tradesBytes, 0)
+		e.mu.Unlock()
+
+		c.evictions.Add(1)
+	}
+}
+
+// retained reports whether analytics are still kept
+func (c *DataCache) retained(analytics *models.MarketAnalytics) bool {
+	return analyticsRetained(analytics, time.Duration(c.staleMaxAge.Load()))
+}
+
+// isExpired checks if a cache entry has expired
 func (c *DataCache) isExpired(entry *CacheEntry) bool {
 	return time.Now().After(entry.ExpiresAt)
 }
 
 // CleanExpired removes expired entries
 func (c *DataCache) CleanExpired() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	// Clean markets
-	if c.markets != nil && c.isExpired(c.markets) {
-		c.markets = nil
+	if entry := c.markets.Load(); entry != nil && c.isExpired(entry) {
+		c.markets.CompareAndSwap(entry, nil)
 	}
 
-	// Clean orderbooks
-	for marketID, entry := range c.orderbooks {
-		if c.isExpired(entry) {
-			delete(c.orderbooks, marketID)
+	c.forEach(func(_ string, e *marketEntry) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		// Clean orderbooks
+		if entry := e.orderbook.Load(); entry != nil && c.isExpired(entry) {
+			e.orderbook.Store(nil)
+			c.resize(&e.orderbookBytes, 0)
 		}
-	}
 
-	// Clean old analytics (older than 1 minute)
-	for marketID, analytics := range c.analytics {
-		if time.Since(analytics.Timestamp) > 60*time.Second {
-			delete(c.analytics, marketID)
+		// Clean old analytics
+		if analytics := e.analytics.Load(); analytics != nil && !c.retained(analytics) {
+			e.analytics.CompareAndSwap(analytics, nil)
 		}
-	}
+	})
 }
 
 // cleanupLoop runs periodic cleanup
@@ -216,3 +413,42 @@ func (c *DataCache) cleanupLoop() {
 		c.CleanExpired()
 	}
 }
+
+// estimateSize approximates the memory held by decoded JSON
+func estimateSize(v interface{}) int64 {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		size := int64(48)
+		for key, item := range value {
+			size += 16 + int64(len(key)) + estimateSize(item)
+		}
+		return size
+	case []interface{}:
+		size := int64(24)
+		for _, item := range value {
+			size += estimateSize(item)
+		}
+		return size
+	case string:
+		return 16 + int64(len(value))
+	default:
+		return 16
+	}
+}
+
+// tradesSize approximates the memory held by a trade list
+func tradesSize(trades []*models.Trade) int64 {
+	size := int64(24)
+	for _, trade := range trades {
+		size += 112 + int64(len(trade.TradeID)+len(trade.MarketID))
+	}
+	return size
+}
+
+// historySize approximates the memory held by a price history
+func historySize(history *models.PriceHistory) int64 {
+	if history == nil {
+		return 0
+	}
+	return 96 + int64(len(history.Prices))*8 + int64(len(history.Times))*24
+}
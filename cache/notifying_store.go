@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/daiwikmh/origami/events"
+	"github.com/daiwikmh/origami/models"
+)
+
+// NotifyingStore wraps a MarketStore and publishes an event on the bus for
+// every write of an orderbook, trades, price history or analytics. Only
+// writes made through this process are seen; replicas sharing a redis store
+// each have their own bus.
+type NotifyingStore struct {
+	MarketStore
+	bus *events.Bus
+}
+
+// NewNotifyingStore wraps store so its writes are published on bus
+func NewNotifyingStore(store MarketStore, bus *events.Bus) *NotifyingStore {
+	return &NotifyingStore{MarketStore: store, bus: bus}
+}
+
+// Unwrap returns the wrapped store
+func (ns *NotifyingStore) Unwrap() MarketStore {
+	return ns.MarketStore
+}
+
+// SetOrderbook stores orderbook and publishes OrderbookUpdated
+func (ns *NotifyingStore) SetOrderbook(marketID string, data interface{}, ttl time.Duration) {
+	ns.MarketStore.SetOrderbook(marketID, data, ttl)
+	ns.bus.Publish(events.Event{Type: events.OrderbookUpdated, MarketID: marketID, Time: time.Now()})
+}
+
+// SetTrades replaces trade history and publishes TradesReplaced
+func (ns *NotifyingStore) SetTrades(marketID string, trades []*models.Trade) {
+	ns.MarketStore.SetTrades(marketID, trades)
+	ns.bus.Publish(events.Event{Type: events.TradesReplaced, MarketID: marketID, Time: time.Now()})
+}
+
+// AppendTrade adds a trade and publishes TradeAppended
+func (ns *NotifyingStore) AppendTrade(marketID string, trade *models.Trade) {
+	ns.MarketStore.AppendTrade(marketID, trade)
+	ns.bus.Publish(events.Event{Type: events.TradeAppended, MarketID: marketID, Time: time.Now(), Trade: trade})
+}
+
+// SetPriceHistory stores price history and publishes PriceHistoryUpdated
+func (ns *NotifyingStore) SetPriceHistory(marketID string, history *models.PriceHistory) {
+	ns.MarketStore.SetPriceHistory(marketID, history)
+	ns.bus.Publish(events.Event{Type: events.PriceHistoryUpdated, MarketID: marketID, Time: time.Now()})
+}
+
+// SetAnalytics stores analytics and publishes AnalyticsRecomputed
+func (ns *NotifyingStore) SetAnalytics(marketID string, analytics *models.MarketAnalytics) {
+	ns.MarketStore.SetAnalytics(marketID, analytics)
+	ns.bus.Publish(events.Event{Type: events.AnalyticsRecomputed, MarketID: marketID, Time: time.Now(), Analytics: analytics})
+}
@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+	"github.com/daiwikmh/origami/resp"
+)
+
+const remoteKeyPrefix = "origami:market:"
+
+// RemoteStore is a MarketStore kept in a Redis-protocol server, shared by all
+// replicas. Values are stored as JSON. Errors are logged and reads treat them
+// as cache misses, so an unavailable server degrades to on-demand fetches.
+type RemoteStore struct {
+	client *resp.Client
+}
+
+// NewRemoteStore creates a store backed by the given client
+func NewRemoteStore(client *resp.Client) *RemoteStore {
+	return &RemoteStore{client: client}
+}
+
+// SetMarkets stores market list with TTL
+func (rs *RemoteStore) SetMarkets(data interface{}, ttl time.Duration) {
+	rs.setJSON(remoteKeyPrefix+"markets", data, ttl)
+}
+
+// GetMarkets retrieves cached markets if not expired
+func (rs *RemoteStore) GetMarkets() (interface{}, bool) {
+	var data map[string]interface{}
+	if !rs.getJSON(remoteKeyPrefix+"markets", &data) {
+		return nil, false
+	}
+	return data, true
+}
+
+// remoteOrderbook wraps a stored orderbook with the time it was stored
+type remoteOrderbook struct {
+	StoredAt time.Time              `json:"stored_at"`
+	Data     map[string]interface{} `json:"data"`
+}
+
+// SetOrderbook stores orderbook for a market with TTL
+func (rs *RemoteStore) SetOrderbook(marketID string, data interface{}, ttl time.Duration) {
+	now := time.Now()
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Market store: encode orderbook failed: %v", err)
+		return
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		log.Printf("Market store: encode orderbook failed: %v", err)
+		return
+	}
+
+	rs.setJSON(remoteKeyPrefix+"orderbook:"+marketID, remoteOrderbook{StoredAt: now, Data: decoded}, ttl)
+}
+
+// GetOrderbook retrieves cached orderbook if not expired
+func (rs *RemoteStore) GetOrderbook(marketID string) (interface{}, bool) {
+	entry, ok := rs.GetOrderbookEntry(marketID)
+	if !ok {
+		return nil, false
+	}
+	return entry.Data, true
+}
+
+// GetOrderbookEntry retrieves cached orderbook with its store time if not expired
+func (rs *RemoteStore) GetOrderbookEntry(marketID string) (*CacheEntry, bool) {
+	var stored remoteOrderbook
+	if !rs.getJSON(remoteKeyPrefix+"orderbook:"+marketID, &stored) || stored.Data == nil {
+		return nil, false
+	}
+	return &CacheEntry{Data: stored.Data, StoredAt: stored.StoredAt}, true
+}
+
+// SetTrades replaces trade history for a market atomically
+func (rs *RemoteStore) SetTrades(marketID string, trades []*models.Trade) {
+	key := remoteKeyPrefix + "trades:" + marketID
+
+	commands := [][]string{{"MULTI"}, {"DEL", key}}
+	if len(trades) > 0 {
+		push := []string{"RPUSH", key}
+		for _, trade := range trades {
+			encoded, err := json.Marshal(trade)
+			if err != nil {
+				continue
+			}
+			push = append(push, string(encoded))
+		}
+		commands = append(commands, push)
+	}
+	commands = append(commands, []string{"EXEC"})
+
+	rs.pipeline("set trades", commands)
+}
+
+// GetTrades retrieves trade history for a market
+func (rs *RemoteStore) GetTrades(marketID string) ([]*models.Trade, bool) {
+	reply, err := rs.client.Do("LRANGE", remoteKeyPrefix+"trades:"+marketID, "0", "-1")
+	if err != nil {
+		log.Printf("Market store: get trades failed: %v", err)
+		return nil, false
+	}
+
+	items, _ := reply.([]interface{})
+	if len(items) == 0 {
+		return nil, false
+	}
+
+	trades := make([]*models.Trade, 0, len(items))
+	for _, item := range items {
+		encoded, _ := item.(string)
+
+		var trade models.Trade
+		if err := json.Unmarshal([]byte(encoded), &trade); err != nil {
+			continue
+		}
+		trades = append(trades, &trade)
+	}
+
+	return trades, true
+}
+
+// AppendTrade adds a new trade and maintains rolling window (max 1000)
+func (rs *RemoteStore) AppendTrade(marketID string, trade *models.Trade) {
+	encoded, err := json.Marshal(trade)
+	if err != nil {
+		return
+	}
+
+	key := remoteKeyPrefix + "trades:" + marketID
+	rs.pipeline("append trade", [][]string{
+		{"RPUSH", key, string(encoded)},
+		{"LTRIM", key, strconv.Itoa(-MaxTrades), "-1"},
+	})
+}
+
+// SetPriceHistory stores price history for a market
+func (rs *RemoteStore) SetPriceHistory(marketID string, history *models.PriceHistory) {
+	rs.setJSON(remoteKeyPrefix+"history:"+marketID, history, 0)
+}
+
+// GetPriceHistory retrieves price history for a market
+func (rs *RemoteStore) GetPriceHistory(marketID string) (*models.PriceHistory, bool) {
+	var history models.PriceHistory
+	if !rs.getJSON(remoteKeyPrefix+"history:"+marketID, &history) {
+		return nil, false
+	}
+	return &history, true
+}
+
+// SetAnalytics stores computed analytics for a market
+func (rs *RemoteStore) SetAnalytics(marketID string, analytics *models.MarketAnalytics) {
+	encoded, err := json.Marshal(analytics)
+	if err != nil {
+		return
+	}
+
+	if _, err := rs.client.Do("HSET", remoteKeyPrefix+"analytics", marketID, string(encoded)); err != nil {
+		log.Printf("Market store: set analytics failed: %v", err)
+	}
+}
+
+// GetAnalytics retrieves the latest cached analytics for a market
+func (rs *RemoteStore) GetAnalytics(marketID string) (*models.MarketAnalytics, bool) {
+	reply, err := rs.client.Do("HGET", remoteKeyPrefix+"analytics", marketID)
+	if err != nil {
+		log.Printf("Market store: get analytics failed: %v", err)
+		return nil, false
+	}
+
+	encoded, ok := reply.(string)
+	if !ok {
+		return nil, false
+	}
+
+	var analytics models.MarketAnalytics
+	if err := json.Unmarshal([]byte(encoded), &analytics); err != nil || !rs.retained(&analytics) {
+		return nil, false
+	}
+
+	return &analytics, true
+}
+
+// GetAllAnalytics retrieves the latest analytics of all markets and drops entries past retention
+func (rs *RemoteStore) GetAllAnalytics() []*models.MarketAnalytics {
+	key := remoteKeyPrefix + "analytics"
+
+	reply, err := rs.client.Do("HGETALL", key)
+	if err != nil {
+		log.Printf("Market store: get all analytics failed: %v", err)
+		return []*models.MarketAnalytics{}
+	}
+
+	items, _ := reply.([]interface{})
+	result := make([]*models.MarketAnalytics, 0, len(items)/2)
+	expired := []string{"HDEL", key}
+
+	for i := 0; i+1 < len(items); i += 2 {
+		marketID, _ := items[i].(string)
+		encoded, _ := items[i+1].(string)
+
+		var analytics models.MarketAnalytics
+		if err := json.Unmarshal([]byte(encoded), &analytics); err != nil {
+			continue
+		}
+
+		if !rs.retained(&analytics) {
+			expired = append(expired, marketID)
+			continue
+		}
+		result = append(result, &analytics)
+	}
+
+	if len(expired) > 2 {
+		rs.pipeline("clean analytics", [][]string{expired})
+	}
+
+	return result
+}
+
+// retained reports whether analytics are still kept. Snapshots are never
+// restored into the remote store, so restored analytics are not kept.
+func (rs *RemoteStore) retained(analytics *models.MarketAnalytics) bool {
+	return analyticsRetained(analytics, 0)
+}
+
+// Touch does nothing, the remote store has no memory budget
+func (rs *RemoteStore) Touch(marketID string) {}
+
+// Backend names the store implementation
+func (rs *RemoteStore) Backend() string {
+	return "redis"
+}
+
+// setJSON stores a JSON value, expiring after ttl when positive
+func (rs *RemoteStore) setJSON(key string, value interface{}, ttl time.Duration) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Market store: encode %s failed: %v", key, err)
+		return
+	}
+
+	args := []string{"SET", key, string(encoded)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+
+	if _, err := rs.client.Do(args...); err != nil {
+		log.Printf("Market store: set %s failed: %v", key, err)
+	}
+}
+
+// getJSON decodes a JSON value into dest, reporting whether it was found
+func (rs *RemoteStore) getJSON(key string, dest interface{}) bool {
+	reply, err := rs.client.Do("GET", key)
+	if err != nil {
+		log.Printf("Market store: get %s failed: %v", key, err)
+		return false
+	}
+
+	encoded, ok := reply.(string)
+	if !ok {
+		return false
+	}
+
+	return json.Unmarshal([]byte(encoded), dest) == nil
+}
+
+// pipeline runs commands, logging transport and error replies
+func (rs *RemoteStore) pipeline(op string, commands [][]string) {
+	replies, err := rs.client.Pipeline(commands)
+	if err != nil {
+		log.Printf("Market store: %s failed: %v", op, err)
+		return
+	}
+
+	for _, reply := range replies {
+		if replyErr, ok := reply.(resp.Error); ok {
+			log.Printf("Market store: %s failed: %v", op, replyErr)
+			return
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// SnapshotVersion is the current on-disk snapshot format. Bump it when the
+// layout changes incompatibly; older versions are rejected on load.
+const SnapshotVersion = 1
+
+// Snapshot is the persisted state of a DataCache. Markets and orderbooks are
+// not included, they are refetched within seconds of a restart.
+type Snapshot struct {
+	Version   int                        `json:"version"`
+	CreatedAt time.Time                  `json:"created_at"`
+	Markets   map[string]*MarketSnapshot `json:"markets"`
+}
+
+// MarketSnapshot is the persisted state of one market
+type MarketSnapshot struct {
+	UpdatedAt    time.Time               `json:"updated_at"` // Newest data point in this entry
+	PriceHistory *models.PriceHistory    `json:"price_history,omitempty"`
+	Trades       []*models.Trade         `json:"trades,omitempty"`
+	Analytics    *models.MarketAnalytics `json:"analytics,omitempty"`
+}
+
+// ExportSnapshot copies price history, trades and analytics into a snapshot
+func (c *DataCache) ExportSnapshot() *Snapshot {
+	snap := &Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: time.Now(),
+		Markets:   make(map[string]*MarketSnapshot),
+	}
+
+	c.forEach(func(marketID string, e *marketEntry) {
+		entry := &MarketSnapshot{}
+
+		if history := e.priceHistory.Load(); history != nil {
+			copied := &models.PriceHistory{
+				MarketID: history.MarketID,
+				Prices:   append([]float64(nil), history.Prices...),
+				Times:    append([]time.Time(nil), history.Times...),
+				MaxSize:  history.MaxSize,
+			}
+
+			entry.PriceHistory = copied
+			if n := len(copied.Times); n > 0 && copied.Times[n-1].After(entry.UpdatedAt) {
+				entry.UpdatedAt = copied.Times[n-1]
+			}
+		}
+
+		if trades := e.trades.Load(); trades != nil && len(*trades) > 0 {
+			entry.Trades = append([]*models.Trade(nil), *trades...)
+			if last := entry.Trades[len(entry.Trades)-1].Timestamp; last.After(entry.UpdatedAt) {
+				entry.UpdatedAt = last
+			}
+		}
+
+		if analytics := e.analytics.Load(); analytics != nil {
+			copied := *analytics
+
+			entry.Analytics = &copied
+			if copied.Timestamp.After(entry.UpdatedAt) {
+				entry.UpdatedAt = copied.Timestamp
+			}
+		}
+
+		if entry.PriceHistory != nil || entry.Trades != nil || entry.Analytics != nil {
+			snap.Markets[marketID] = entry
+		}
+	})
+
+	return snap
+}
+
+// ImportSnapshot loads a snapshot into an empty cache. Markets whose newest
+// data is older than maxAge are skipped. Restored analytics are flagged and
+// served as stale until they are recomputed from live data.
+func (c *DataCache) ImportSnapshot(snap *Snapshot, maxAge time.Duration) int {
+	c.staleMaxAge.Store(int64(maxAge))
+
+	restored := 0
+	for marketID, entry := range snap.Markets {
+		if time.Since(entry.UpdatedAt) > maxAge {
+			continue
+		}
+
+		if entry.PriceHistory != nil && len(entry.PriceHistory.Prices) == len(entry.PriceHistory.Times) {
+			if entry.PriceHistory.MaxSize <= 0 {
+				entry.PriceHistory.MaxSize = models.NewPriceHistory(marketID).MaxSize
+			}
+			c.SetPriceHistory(marketID, entry.PriceHistory)
+		}
+
+		if len(entry.Trades) > 0 {
+			c.SetTrades(marketID, entry.Trades)
+		}
+
+		if entry.Analytics != nil {
+			entry.Analytics.Restored = true
+			c.SetAnalytics(marketID, entry.Analytics)
+		}
+
+		restored++
+	}
+
+	return restored
+}
+
+// WriteSnapshot writes a gzip-compressed JSON snapshot. The file is replaced
+// atomically so a crash mid-write never leaves a truncated snapshot.
+func WriteSnapshot(path string, snap *Snapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(zw).Encode(snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot
+func ReadSnapshot(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var snap Snapshot
+	if err := json.NewDecoder(zr).Decode(&snap); err != nil {
+		return nil, err
+	}
+
+	if snap.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d (expected %d)", snap.Version, SnapshotVersion)
+	}
+
+	return &snap, nil
+}
+
+// Snapshotter periodically persists a DataCache to disk
+type Snapshotter struct {
+	cache    *DataCache
+	path     string
+	interval time.Duration
+	stopChan chan bool
+	wg       sync.WaitGroup
+}
+
+// NewSnapshotter creates a snapshotter writing to path every interval
+func NewSnapshotter(cache *DataCache, path string, interval time.Duration) *Snapshotter {
+	return &Snapshotter{
+		cache:    cache,
+		path:     path,
+		interval: interval,
+		stopChan: make(chan bool),
+	}
+}
+
+// Restore loads the snapshot on disk, if any, into the cache
+func (s *Snapshotter) Restore(maxAge time.Duration) {
+	snap, err := ReadSnapshot(s.path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Ignoring snapshot %s: %v", s.path, err)
+		return
+	}
+
+	if age := time.Since(snap.CreatedAt); age > maxAge {
+		log.Printf("Ignoring snapshot %s: %s old (max %s)", s.path, age.Round(time.Second), maxAge)
+		return
+	}
+
+	restored := s.cache.ImportSnapshot(snap, maxAge)
+	log.Printf("Restored %d markets from snapshot taken %s ago", restored, time.Since(snap.CreatedAt).Round(time.Second))
+}
+
+// Start begins periodic snapshots
+func (s *Snapshotter) Start() {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.save()
+			}
+		}
+	}()
+}
+
+// Stop ends periodic snapshots and writes a final one
+func (s *Snapshotter) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+	s.save()
+}
+
+// save writes the current cache state
+func (s *Snapshotter) save() {
+	if err := WriteSnapshot(s.path, s.cache.ExportSnapshot()); err != nil {
+		log.Printf("Error writing snapshot: %v", err)
+	}
+}
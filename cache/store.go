@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+const (
+	// MaxTrades is the rolling window of trades kept per market
+	MaxTrades = 1000
+
+	// analyticsRetention is how long analytics are kept before cleanup.
+	// Readers decide how old analytics may be served; this bounds it.
+	analyticsRetention = time.Hour
+)
+
+// MarketStore holds collected market data: market list, orderbooks, trades,
+// price history and computed analytics. Workers write to it and services read
+// from it, so a store shared between replicas lets every replica serve the
+// same collected data.
+type MarketStore interface {
+	// SetMarkets stores the market list with TTL
+	SetMarkets(data interface{}, ttl time.Duration)
+	// GetMarkets retrieves the market list if not expired
+	GetMarkets() (interface{}, bool)
+
+	// SetOrderbook stores the orderbook of a market with TTL
+	SetOrderbook(marketID string, data interface{}, ttl time.Duration)
+	// GetOrderbook retrieves the orderbook of a market if not expired
+	GetOrderbook(marketID string) (interface{}, bool)
+	// GetOrderbookEntry is GetOrderbook with the time the orderbook was stored
+	GetOrderbookEntry(marketID string) (*CacheEntry, bool)
+
+	// SetTrades replaces the trade history of a market
+	SetTrades(marketID string, trades []*models.Trade)
+	// GetTrades retrieves the trade history of a market
+	GetTrades(marketID string) ([]*models.Trade, bool)
+	// AppendTrade adds a trade, keeping the most recent 1000
+	AppendTrade(marketID string, trade *models.Trade)
+
+	// SetPriceHistory stores the price history of a market
+	SetPriceHistory(marketID string, history *models.PriceHistory)
+	// GetPriceHistory retrieves the price history of a market
+	GetPriceHistory(marketID string) (*models.PriceHistory, bool)
+
+	// SetAnalytics stores computed analytics for a market
+	SetAnalytics(marketID string, analytics *models.MarketAnalytics)
+	// GetAnalytics retrieves the latest analytics for a market whatever their
+	// age; callers decide whether they are fresh enough
+	GetAnalytics(marketID string) (*models.MarketAnalytics, bool)
+	// GetAllAnalytics retrieves the latest analytics of all markets (for trending calculations)
+	GetAllAnalytics() []*models.MarketAnalytics
+
+	// Touch marks a market as read by a client, so stores with a memory
+	// budget evict the markets clients stopped reading first
+	Touch(marketID string)
+
+	// Backend names the implementation, for logs and system info
+	Backend() string
+}
+
+// analyticsRetained reports whether analytics are still kept. Analytics
+// restored from a snapshot are kept while the snapshot is usable, restoredMaxAge.
+func analyticsRetained(analytics *models.MarketAnalytics, restoredMaxAge time.Duration) bool {
+	if analytics.Restored {
+		return time.Since(analytics.Timestamp) <= restoredMaxAge
+	}
+	return time.Since(analytics.Timestamp) <= analyticsRetention
+}
@@ -6,9 +6,11 @@ import (
 	"net/http"
 )
 
-// FetchTrades retrieves recent trades for a market
+// FetchTrades retrieves recent trades for a market. Each match is reported
+// once per side, so only the taker fill is requested: its direction is the
+// aggressor's and its quantity counts the match once.
 func FetchTrades(marketID string, limit int) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/api/exchange/spot/v2/trades?marketIds=%s&limit=%d", BASE_URL, marketID, limit)
+	url := fmt.Sprintf("%s/api/exchange/spot/v2/trades?marketIds=%s&executionSide=taker&limit=%d", BASE_URL, marketID, limit)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -0,0 +1,72 @@
+// Package coalesce merges concurrent calls for the same key into one, so a
+// burst of requests on a cold cache costs a single upstream fetch.
+package coalesce
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// call is an in-flight or completed Do call
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Group coalesces calls by key and counts how many were issued and how many
+// joined a call already in flight
+type Group struct {
+	calls     map[string]*call
+	mu        sync.Mutex
+	issued    atomic.Int64
+	coalesced atomic.Int64
+}
+
+// Stats counts calls made through a Group
+type Stats struct {
+	Issued    int64 `json:"issued"`    // Calls that ran fn
+	Coalesced int64 `json:"coalesced"` // Calls that waited for another caller's fn
+}
+
+// NewGroup creates an empty group
+func NewGroup() *Group {
+	return &Group{calls: make(map[string]*call)}
+}
+
+// Do runs fn once for all concurrent callers with the same key and returns
+// its result to each of them. shared reports whether the result came from
+// another caller's fn.
+func (g *Group) Do(key string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		g.coalesced.Add(1)
+		c.wg.Wait()
+		return c.value, c.err, true
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	g.issued.Add(1)
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.value, c.err = fn()
+	return c.value, c.err, false
+}
+
+// Stats returns the call counters of the group
+func (g *Group) Stats() Stats {
+	return Stats{
+		Issued:    g.issued.Load(),
+		Coalesced: g.coalesced.Load(),
+	}
+}
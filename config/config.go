@@ -0,0 +1,185 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds runtime settings loaded from environment variables
+type Config struct {
+	Port string
+
+	// Access tokens
+	JWTSecret         string
+	AccessTokenTTL    time.Duration
+	MaxAccessTokenTTL time.Duration
+
+	// HMAC request signing
+	SignatureMaxSkew time.Duration
+
+	// Shared counter store (Redis protocol). Empty keeps counters in memory.
+	RedisAddr     string
+	RedisPassword string
+
+	// API keys are stored in the shared store too, and replicas pick up keys
+	// changed elsewhere every KeySyncInterval
+	KeySyncInterval time.Duration
+
+	// Market data store: "memory" or "redis" (shared, uses RedisAddr)
+	MarketStore string
+	// Memory budget of the in-memory market store in MB. Orderbooks and
+	// trades of the least recently used markets are evicted beyond it.
+	CacheMaxMB int
+	// Run the background collectors. Replicas sharing a redis market store
+	// can leave collection to one instance.
+	Collector bool
+
+	// Snapshots of collected market state for warm restarts ("off" disables)
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	SnapshotMaxAge   time.Duration // Older snapshots and market entries are not restored
+
+	// Embedded time-series store ("off" disables) and per-resolution retention,
+	// e.g. "raw=168h,1m=720h,5m=2160h,1h=8760h,1d=0"
+	TSDBPath      string
+	TSDBRetention string
+
+	// Maximum number of bars per candles request
+	CandlesMaxBars int
+
+	// Analytics younger than AnalyticsFreshFor are served as fresh. Older ones
+	// are served flagged stale while being recomputed, up to AnalyticsMaxAge.
+	AnalyticsFreshFor time.Duration
+	AnalyticsMaxAge   time.Duration
+
+	// Analytics are recomputed when a market's data changes, after waiting
+	// AnalyticsDebounce for further changes and at most once per AnalyticsMinInterval
+	AnalyticsDebounce    time.Duration
+	AnalyticsMinInterval time.Duration
+
+	// Default trending score weights, e.g. "volume:0.4,volatility:0.3,price_change:0.3",
+	// and method ("percentile" or "zscore")
+	TrendingWeights string
+	TrendingMethod  string
+
+	// Abuse detection
+	AbuseDetection  bool
+	AbuseWebhookURL string
+
+	// Public routes and browser hardening
+	EnableDashboard bool     // Serve the dashboard (/) and API tester (/test)
+	PublicRateLimit int      // Requests per minute per client IP on unauthenticated routes
+	TrustedProxies  []string // Proxies allowed to set X-Forwarded-For; empty trusts none
+	TLSCertFile     string
+	TLSKeyFile      string
+	HSTS            bool // Send HSTS on plain HTTP too, for TLS terminated at a proxy
+}
+
+// Load reads configuration from the environment, applying defaults
+func Load() *Config {
+	return &Config{
+		Port:                 getEnv("PORT", "8080"),
+		JWTSecret:            os.Getenv("ORIGAMI_JWT_SECRET"),
+		AccessTokenTTL:       getDuration("ORIGAMI_ACCESS_TOKEN_TTL", 15*time.Minute),
+		MaxAccessTokenTTL:    getDuration("ORIGAMI_ACCESS_TOKEN_MAX_TTL", time.Hour),
+		SignatureMaxSkew:     getDuration("ORIGAMI_SIGNATURE_MAX_SKEW", 5*time.Minute),
+		RedisAddr:            os.Getenv("ORIGAMI_REDIS_ADDR"),
+		RedisPassword:        os.Getenv("ORIGAMI_REDIS_PASSWORD"),
+		KeySyncInterval:      getDuration("ORIGAMI_KEY_SYNC_INTERVAL", 10*time.Second),
+		MarketStore:          getEnv("ORIGAMI_MARKET_STORE", "memory"),
+		CacheMaxMB:           getInt("ORIGAMI_CACHE_MAX_MB", 256),
+		Collector:            getBool("ORIGAMI_COLLECTOR", true),
+		SnapshotPath:         getEnv("ORIGAMI_SNAPSHOT_PATH", "data/market-snapshot.json.gz"),
+		SnapshotInterval:     getDuration("ORIGAMI_SNAPSHOT_INTERVAL", time.Minute),
+		SnapshotMaxAge:       getDuration("ORIGAMI_SNAPSHOT_MAX_AGE", 6*time.Hour),
+		TSDBPath:             getEnv("ORIGAMI_TSDB_PATH", "data/tsdb"),
+		TSDBRetention:        os.Getenv("ORIGAMI_TSDB_RETENTION"),
+		CandlesMaxBars:       getInt("ORIGAMI_CANDLES_MAX_BARS", 1000),
+		AnalyticsFreshFor:    getDuration("ORIGAMI_ANALYTICS_FRESH_FOR", 15*time.Second),
+		AnalyticsMaxAge:      getDuration("ORIGAMI_ANALYTICS_MAX_AGE", 2*time.Minute),
+		AnalyticsDebounce:    getDuration("ORIGAMI_ANALYTICS_DEBOUNCE", 500*time.Millisecond),
+		AnalyticsMinInterval: getDuration("ORIGAMI_ANALYTICS_MIN_INTERVAL", 2*time.Second),
+		TrendingWeights:      getEnv("ORIGAMI_TRENDING_WEIGHTS", "volume:0.4,volatility:0.3,price_change:0.3"),
+		TrendingMethod:       getEnv("ORIGAMI_TRENDING_METHOD", "percentile"),
+		AbuseDetection:       getBool("ORIGAMI_ABUSE_DETECTION", true),
+		AbuseWebhookURL:      os.Getenv("ORIGAMI_ABUSE_WEBHOOK_URL"),
+		EnableDashboard:      getBool("ORIGAMI_ENABLE_DASHBOARD", true),
+		PublicRateLimit:      getInt("ORIGAMI_PUBLIC_RATE_LIMIT", 60),
+		TrustedProxies:       getList("ORIGAMI_TRUSTED_PROXIES"),
+		TLSCertFile:          os.Getenv("ORIGAMI_TLS_CERT"),
+		TLSKeyFile:           os.Getenv("ORIGAMI_TLS_KEY"),
+		HSTS:                 getBool("ORIGAMI_HSTS", false),
+	}
+}
+
+// TLSEnabled reports whether the server terminates TLS itself
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// getEnv returns the variable value or a fallback when unset
+func getEnv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getDuration parses a Go duration string such as "15m"
+func getDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s=%q, using default %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getInt parses a positive integer variable
+func getInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s=%q, using default %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getList splits a comma-separated variable, dropping empty entries
+func getList(name string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getBool parses a boolean variable such as "true" or "0"
+func getBool(name string, fallback bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %t", name, value, fallback)
+		return fallback
+	}
+	return b
+}
@@ -0,0 +1,167 @@
+package counters
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/resp"
+)
+
+// FailoverStore uses a shared primary store and falls back to a local store
+// while the primary is unavailable. Limits then apply per instance until the
+// primary recovers. Hash increments made meanwhile are replayed into the
+// primary once it is reachable again.
+type FailoverStore struct {
+	primary    Store
+	local      Store
+	retryAfter time.Duration
+	downUntil  time.Time
+	pending    map[string]map[string]int64 // Hash increments not yet applied to the primary
+	mu         sync.RWMutex
+	pendingMu  sync.Mutex
+}
+
+// NewFailoverStore wraps primary with a local fallback. After a failure the
+// primary is skipped for retryAfter before being tried again.
+func NewFailoverStore(primary, local Store, retryAfter time.Duration) *FailoverStore {
+	return &FailoverStore{
+		primary:    primary,
+		local:      local,
+		retryAfter: retryAfter,
+		pending:    make(map[string]map[string]int64),
+	}
+}
+
+// IncrWindow increments a fixed-window counter
+func (fs *FailoverStore) IncrWindow(key string, window time.Duration) (int64, error) {
+	if fs.primaryAvailable() {
+		count, err := fs.primary.IncrWindow(key, window)
+		if !fs.unavailable(err) {
+			return count, err
+		}
+	}
+
+	return fs.local.IncrWindow(key, window)
+}
+
+// HIncr increments a hash field
+func (fs *FailoverStore) HIncr(key, field string, delta int64) error {
+	if fs.primaryAvailable() && fs.replay() {
+		err := fs.primary.HIncr(key, field, delta)
+		if !fs.unavailable(err) {
+			return err
+		}
+	}
+
+	fs.pendingMu.Lock()
+	defer fs.pendingMu.Unlock()
+
+	fs.addPending(key, field, delta)
+	return nil
+}
+
+// HGetAll returns all fields of a hash. While the primary is down only the
+// increments made since are known.
+func (fs *FailoverStore) HGetAll(key string) (map[string]int64, error) {
+	if fs.primaryAvailable() && fs.replay() {
+		result, err := fs.primary.HGetAll(key)
+		if !fs.unavailable(err) {
+			return result, err
+		}
+	}
+
+	fs.pendingMu.Lock()
+	defer fs.pendingMu.Unlock()
+
+	result := make(map[string]int64, len(fs.pending[key]))
+	for field, value := range fs.pending[key] {
+		result[field] = value
+	}
+	return result, nil
+}
+
+// SetNX records a key unless it is already recorded
+func (fs *FailoverStore) SetNX(key string, ttl time.Duration) (bool, error) {
+	if fs.primaryAvailable() {
+		set, err := fs.primary.SetNX(key, ttl)
+		if !fs.unavailable(err) {
+			return set, err
+		}
+	}
+
+	return fs.local.SetNX(key, ttl)
+}
+
+// Backend reports the primary backend and whether it is degraded
+func (fs *FailoverStore) Backend() string {
+	if fs.primaryAvailable() {
+		return fs.primary.Backend()
+	}
+	return fs.primary.Backend() + " (unavailable, using " + fs.local.Backend() + ")"
+}
+
+// replay applies pending hash increments to the primary and reports whether
+// it is still available. Increments are kept if it can't be reached.
+func (fs *FailoverStore) replay() bool {
+	fs.pendingMu.Lock()
+	defer fs.pendingMu.Unlock()
+
+	for key, fields := range fs.pending {
+		for field, delta := range fields {
+			err := fs.primary.HIncr(key, field, delta)
+			if fs.unavailable(err) {
+				return false
+			}
+			if err != nil {
+				log.Printf("Dropping %s %s increment of %d: %v", key, field, delta, err)
+			}
+			delete(fields, field)
+		}
+		delete(fs.pending, key)
+	}
+
+	return true
+}
+
+// addPending records a hash increment for replay (caller must hold pendingMu)
+func (fs *FailoverStore) addPending(key, field string, delta int64) {
+	fields, exists := fs.pending[key]
+	if !exists {
+		fields = make(map[string]int64)
+		fs.pending[key] = fields
+	}
+	fields[field] += delta
+}
+
+// primaryAvailable reports whether the primary should be tried
+func (fs *FailoverStore) primaryAvailable() bool {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return time.Now().After(fs.downUntil)
+}
+
+// unavailable reports whether err means the primary can't be reached, and
+// skips it for the retry period if so. Error replies are left to the caller.
+func (fs *FailoverStore) unavailable(err error) bool {
+	var connErr *resp.ConnError
+	if !errors.As(err, &connErr) {
+		return false
+	}
+
+	fs.markDown(err)
+	return true
+}
+
+// markDown skips the primary for the retry period
+func (fs *FailoverStore) markDown(err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if time.Now().After(fs.downUntil) {
+		log.Printf("Counter store %s unavailable, falling back to %s: %v", fs.primary.Backend(), fs.local.Backend(), err)
+	}
+	fs.downUntil = time.Now().Add(fs.retryAfter)
+}
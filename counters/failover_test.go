@@ -0,0 +1,147 @@
+package counters
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daiwikmh/origami/resp"
+)
+
+func TestFailoverStoreDegradesToLocal(t *testing.T) {
+	server := newRedisServer(t)
+	store := NewFailoverStore(newRedisStore(t, server), NewMemoryStore(), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := store.IncrWindow("ratelimit:key", time.Hour); err != nil {
+			t.Fatalf("IncrWindow: %v", err)
+		}
+	}
+	if backend := store.Backend(); backend != "redis" {
+		t.Fatalf("Backend = %q, want redis", backend)
+	}
+
+	server.Close()
+
+	// Requests keep being counted, per instance, without errors
+	count, err := store.IncrWindow("ratelimit:key", time.Hour)
+	if err != nil {
+		t.Fatalf("IncrWindow while down: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("count while down = %d, want 1 from the local store", count)
+	}
+	if count, _ := store.IncrWindow("ratelimit:key", time.Hour); count != 2 {
+		t.Errorf("second count while down = %d, want 2", count)
+	}
+
+	if err := store.HIncr("usage:key", "requests", 1); err != nil {
+		t.Errorf("HIncr while down: %v", err)
+	}
+	if usage, err := store.HGetAll("usage:key"); err != nil || usage["requests"] != 1 {
+		t.Errorf("HGetAll while down = %v, %v; want requests 1", usage, err)
+	}
+	if set, err := store.SetNX("nonce:1", time.Minute); err != nil || !set {
+		t.Errorf("SetNX while down = %v, %v; want true", set, err)
+	}
+
+	if backend := store.Backend(); !strings.Contains(backend, "unavailable") {
+		t.Errorf("Backend while down = %q, want it flagged unavailable", backend)
+	}
+}
+
+func TestFailoverStoreRecovers(t *testing.T) {
+	server := newRedisServer(t)
+	store := NewFailoverStore(newRedisStore(t, server), NewMemoryStore(), 50*time.Millisecond)
+
+	if count, err := store.IncrWindow("ratelimit:key", time.Hour); err != nil || count != 1 {
+		t.Fatalf("IncrWindow = %d, %v; want 1", count, err)
+	}
+
+	server.Close()
+	if _, err := store.IncrWindow("ratelimit:key", time.Hour); err != nil {
+		t.Fatalf("IncrWindow while down: %v", err)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("restarting server: %v", err)
+	}
+
+	// The primary is skipped until the retry period passes
+	if count, _ := store.IncrWindow("ratelimit:key", time.Hour); count != 2 {
+		t.Errorf("count before retry = %d, want 2 from the local store", count)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if count, err := store.IncrWindow("ratelimit:key", time.Hour); err != nil || count != 2 {
+		t.Errorf("count after recovery = %d, %v; want 2 from the shared store", count, err)
+	}
+	if backend := store.Backend(); backend != "redis" {
+		t.Errorf("Backend after recovery = %q, want redis", backend)
+	}
+}
+
+func TestFailoverStoreReturnsReplyErrors(t *testing.T) {
+	server := newRedisServer(t)
+	store := NewFailoverStore(newRedisStore(t, server), NewMemoryStore(), time.Minute)
+
+	// A hash increment on a string key is rejected by the server, which is
+	// still reachable
+	if _, err := store.SetNX("nonce:1", time.Minute); err != nil {
+		t.Fatalf("SetNX: %v", err)
+	}
+	err := store.HIncr("nonce:1", "requests", 1)
+	if _, ok := err.(resp.Error); !ok {
+		t.Fatalf("HIncr on a string key = %v, want the error reply", err)
+	}
+
+	if backend := store.Backend(); backend != "redis" {
+		t.Errorf("Backend after an error reply = %q, want redis", backend)
+	}
+	if count, err := store.IncrWindow("ratelimit:key", time.Hour); err != nil || count != 1 {
+		t.Errorf("IncrWindow = %d, %v; want 1 from the shared store", count, err)
+	}
+}
+
+func TestFailoverStoreReplaysHashIncrements(t *testing.T) {
+	server := newRedisServer(t)
+	store := NewFailoverStore(newRedisStore(t, server), NewMemoryStore(), 50*time.Millisecond)
+	replica := newRedisStore(t, server)
+
+	if err := store.HIncr("usage:key", "requests", 2); err != nil {
+		t.Fatalf("HIncr: %v", err)
+	}
+
+	server.Close()
+	for i := 0; i < 3; i++ {
+		if err := store.HIncr("usage:key", "requests", 1); err != nil {
+			t.Fatalf("HIncr while down: %v", err)
+		}
+	}
+	if err := store.HIncr("usage:key", "endpoint:/api/v1/markets", 1); err != nil {
+		t.Fatalf("HIncr while down: %v", err)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("restarting server: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	// Increments counted during the outage reach the shared store
+	usage, err := store.HGetAll("usage:key")
+	if err != nil || usage["requests"] != 5 || usage["endpoint:/api/v1/markets"] != 1 {
+		t.Fatalf("HGetAll after recovery = %v, %v; want requests 5 and one endpoint hit", usage, err)
+	}
+	if usage, err := replica.HGetAll("usage:key"); err != nil || usage["requests"] != 5 {
+		t.Errorf("HGetAll from another replica = %v, %v; want requests 5", usage, err)
+	}
+
+	// Replayed increments are not applied twice
+	if err := store.HIncr("usage:key", "requests", 1); err != nil {
+		t.Fatalf("HIncr: %v", err)
+	}
+	if usage, _ := replica.HGetAll("usage:key"); usage["requests"] != 6 {
+		t.Errorf("requests = %d, want 6", usage["requests"])
+	}
+}
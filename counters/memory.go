@@ -0,0 +1,120 @@
+package counters
+
+import (
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// MemoryStore keeps counters in process memory
+type MemoryStore struct {
+	windows   map[string]*models.RateLimitInfo
+	hashes    map[string]map[string]int64
+	marks     map[string]time.Time // Keys set by SetNX and their expiry
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		windows:   make(map[string]*models.RateLimitInfo),
+		hashes:    make(map[string]map[string]int64),
+		marks:     make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// IncrWindow increments a fixed-window counter
+func (ms *MemoryStore) IncrWindow(key string, window time.Duration) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	ms.sweep(now)
+
+	windowStart := time.Unix(0, windowIndex(now, window)*int64(window))
+	info, exists := ms.windows[key]
+
+	// If no record or window expired, create new window
+	if !exists || !info.WindowStart.Equal(windowStart) {
+		ms.windows[key] = &models.RateLimitInfo{
+			WindowStart: windowStart,
+			Window:      window,
+			Count:       1,
+		}
+		return 1, nil
+	}
+
+	info.Count++
+	return int64(info.Count), nil
+}
+
+// HIncr increments a hash field
+func (ms *MemoryStore) HIncr(key, field string, delta int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	hash, exists := ms.hashes[key]
+	if !exists {
+		hash = make(map[string]int64)
+		ms.hashes[key] = hash
+	}
+
+	hash[field] += delta
+	return nil
+}
+
+// HGetAll returns a copy of a hash
+func (ms *MemoryStore) HGetAll(key string) (map[string]int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	result := make(map[string]int64, len(ms.hashes[key]))
+	for field, value := range ms.hashes[key] {
+		result[field] = value
+	}
+
+	return result, nil
+}
+
+// SetNX records a key until its ttl passes
+func (ms *MemoryStore) SetNX(key string, ttl time.Duration) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	ms.sweep(now)
+
+	if expiry, exists := ms.marks[key]; exists && now.Before(expiry) {
+		return false, nil
+	}
+
+	ms.marks[key] = now.Add(ttl)
+	return true, nil
+}
+
+// Backend identifies the store
+func (ms *MemoryStore) Backend() string {
+	return "memory"
+}
+
+// sweep drops expired windows and keys once a minute (caller must hold the lock)
+func (ms *MemoryStore) sweep(now time.Time) {
+	if now.Sub(ms.lastSweep) < time.Minute {
+		return
+	}
+
+	for key, info := range ms.windows {
+		if now.Sub(info.WindowStart) >= info.Window {
+			delete(ms.windows, key)
+		}
+	}
+	for key, expiry := range ms.marks {
+		if !now.Before(expiry) {
+			delete(ms.marks, key)
+		}
+	}
+	ms.lastSweep = now
+}
@@ -0,0 +1,96 @@
+package counters
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/daiwikmh/origami/resp"
+)
+
+const keyPrefix = "origami:"
+
+// RedisStore keeps counters in a Redis-protocol server shared by all replicas
+type RedisStore struct {
+	client *resp.Client
+}
+
+// NewRedisStore creates a store backed by the given client
+func NewRedisStore(client *resp.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// IncrWindow increments a fixed-window counter. The key expires with its window.
+func (rs *RedisStore) IncrWindow(key string, window time.Duration) (int64, error) {
+	windowKey := fmt.Sprintf("%s%s:%d", keyPrefix, key, windowIndex(time.Now(), window))
+
+	replies, err := rs.client.Pipeline([][]string{
+		{"INCR", windowKey},
+		{"PEXPIRE", windowKey, strconv.FormatInt(window.Milliseconds()*2, 10)},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, reply := range replies {
+		if replyErr, ok := reply.(resp.Error); ok {
+			return 0, replyErr
+		}
+	}
+
+	count, ok := replies[0].(int64)
+	if !ok {
+		return 0, fmt.Errorf("counters: unexpected INCR reply %T", replies[0])
+	}
+
+	return count, nil
+}
+
+// HIncr increments a hash field
+func (rs *RedisStore) HIncr(key, field string, delta int64) error {
+	_, err := rs.client.Do("HINCRBY", keyPrefix+key, field, strconv.FormatInt(delta, 10))
+	return err
+}
+
+// HGetAll returns all fields of a hash
+func (rs *RedisStore) HGetAll(key string) (map[string]int64, error) {
+	reply, err := rs.client.Do("HGETALL", keyPrefix+key)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("counters: unexpected HGETALL reply %T", reply)
+	}
+
+	result := make(map[string]int64, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		field, _ := items[i].(string)
+		raw, _ := items[i+1].(string)
+
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		result[field] = value
+	}
+
+	return result, nil
+}
+
+// SetNX sets a key that expires after ttl, unless it exists
+func (rs *RedisStore) SetNX(key string, ttl time.Duration) (bool, error) {
+	reply, err := rs.client.Do("SET", keyPrefix+key, "1", "NX", "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	if err != nil {
+		return false, err
+	}
+
+	// OK when set, null when the key exists
+	return reply != nil, nil
+}
+
+// Backend identifies the store
+func (rs *RedisStore) Backend() string {
+	return "redis"
+}
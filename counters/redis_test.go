@@ -0,0 +1,91 @@
+package counters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daiwikmh/origami/resp"
+	"github.com/daiwikmh/origami/resp/resptest"
+)
+
+func newRedisServer(t *testing.T) *resptest.Server {
+	t.Helper()
+
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newRedisStore(t *testing.T, server *resptest.Server) *RedisStore {
+	t.Helper()
+
+	client := resp.NewClient(server.Addr, "", 2, time.Second)
+	t.Cleanup(client.Close)
+	return NewRedisStore(client)
+}
+
+func TestRedisStoreSharesLimits(t *testing.T) {
+	server := newRedisServer(t)
+	replicas := []*RedisStore{newRedisStore(t, server), newRedisStore(t, server)}
+
+	// Two replicas counting one key see a single window
+	for i := int64(1); i <= 6; i++ {
+		count, err := replicas[i%2].IncrWindow("ratelimit:key", time.Hour)
+		if err != nil {
+			t.Fatalf("IncrWindow: %v", err)
+		}
+		if count != i {
+			t.Fatalf("request %d counted as %d", i, count)
+		}
+	}
+
+	if count, _ := replicas[0].IncrWindow("ratelimit:other", time.Hour); count != 1 {
+		t.Errorf("other key counted as %d, want 1", count)
+	}
+}
+
+func TestRedisStoreSharesUsage(t *testing.T) {
+	server := newRedisServer(t)
+	a, b := newRedisStore(t, server), newRedisStore(t, server)
+
+	if err := a.HIncr("usage:key", "requests", 3); err != nil {
+		t.Fatalf("HIncr: %v", err)
+	}
+	if err := b.HIncr("usage:key", "requests", 2); err != nil {
+		t.Fatalf("HIncr: %v", err)
+	}
+	if err := b.HIncr("usage:key", "errors", 1); err != nil {
+		t.Fatalf("HIncr: %v", err)
+	}
+
+	usage, err := a.HGetAll("usage:key")
+	if err != nil {
+		t.Fatalf("HGetAll: %v", err)
+	}
+	if usage["requests"] != 5 || usage["errors"] != 1 || len(usage) != 2 {
+		t.Errorf("usage = %v, want requests 5 and errors 1", usage)
+	}
+}
+
+func TestRedisStoreSharesSetNX(t *testing.T) {
+	server := newRedisServer(t)
+	a, b := newRedisStore(t, server), newRedisStore(t, server)
+
+	if set, err := a.SetNX("nonce:1", time.Minute); err != nil || !set {
+		t.Fatalf("first SetNX = %v, %v; want true", set, err)
+	}
+	if set, err := b.SetNX("nonce:1", time.Minute); err != nil || set {
+		t.Fatalf("SetNX on another replica = %v, %v; want false", set, err)
+	}
+
+	if set, _ := a.SetNX("nonce:2", 20*time.Millisecond); !set {
+		t.Fatal("SetNX of a new key = false")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if set, _ := b.SetNX("nonce:2", time.Minute); !set {
+		t.Error("SetNX after expiry = false, want true")
+	}
+}
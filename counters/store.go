@@ -0,0 +1,30 @@
+package counters
+
+import "time"
+
+// Store holds rate limit windows and usage counters. Implementations may be
+// process-local or shared between API replicas.
+type Store interface {
+	// IncrWindow increments the counter for key in the current fixed window
+	// and returns the count so far in that window
+	IncrWindow(key string, window time.Duration) (int64, error)
+
+	// HIncr increments a field of the hash counter stored at key
+	HIncr(key, field string, delta int64) error
+
+	// HGetAll returns every field of the hash counter stored at key
+	HGetAll(key string) (map[string]int64, error)
+
+	// SetNX records key for ttl unless it is already recorded, and reports
+	// whether it was recorded by this call
+	SetNX(key string, ttl time.Duration) (bool, error)
+
+	// Backend describes the active backend for diagnostics
+	Backend() string
+}
+
+// windowIndex returns the fixed window number containing t. Every replica
+// computes the same index, so windows line up across instances.
+func windowIndex(t time.Time, window time.Duration) int64 {
+	return t.UnixNano() / int64(window)
+}
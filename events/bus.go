@@ -0,0 +1,203 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DropPolicy decides which event is lost when a subscriber's buffer is full
+type DropPolicy string
+
+// Drop policies
+const (
+	DropNewest DropPolicy = "drop_newest" // Discard the event being published
+	DropOldest DropPolicy = "drop_oldest" // Discard the oldest buffered event to make room
+)
+
+// defaultBuffer is the buffer size of subscriptions that don't set one
+const defaultBuffer = 256
+
+// Filter selects the events delivered to a subscription. Empty fields match all.
+type Filter struct {
+	Types    []Type
+	MarketID string
+}
+
+// matches reports whether an event passes the filter
+func (f Filter) matches(e Event) bool {
+	if f.MarketID != "" && f.MarketID != e.MarketID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription receives matching events on C until it is closed
+type Subscription struct {
+	C <-chan Event
+
+	name      string
+	ch        chan Event
+	filter    Filter
+	policy    DropPolicy
+	delivered atomic.Int64
+	dropped   atomic.Int64
+	bus       *Bus
+}
+
+// SubscriptionStats counts the events of one subscription
+type SubscriptionStats struct {
+	Name      string     `json:"name"`
+	Policy    DropPolicy `json:"policy"`
+	Buffer    int        `json:"buffer"`
+	Queued    int        `json:"queued"`
+	Delivered int64      `json:"delivered"`
+	Dropped   int64      `json:"dropped"`
+}
+
+// Stats describes a bus and its subscriptions
+type Stats struct {
+	Published     map[Type]int64      `json:"published"`
+	Subscriptions []SubscriptionStats `json:"subscriptions"`
+}
+
+// Bus fans published events out to subscriptions
+type Bus struct {
+	subs      []*Subscription
+	published map[Type]*atomic.Int64
+	mu        sync.RWMutex
+}
+
+// NewBus creates a bus without subscriptions
+func NewBus() *Bus {
+	published := make(map[Type]*atomic.Int64, len(Types))
+	for _, t := range Types {
+		published[t] = &atomic.Int64{}
+	}
+
+	return &Bus{published: published}
+}
+
+// Subscribe registers a named subscription with a buffer of the given size
+// (default 256) and drop policy (default DropOldest)
+func (b *Bus) Subscribe(name string, filter Filter, buffer int, policy DropPolicy) *Subscription {
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	if policy != DropNewest {
+		policy = DropOldest
+	}
+
+	ch := make(chan Event, buffer)
+	sub := &Subscription{
+		C:      ch,
+		name:   name,
+		ch:     ch,
+		filter: filter,
+		policy: policy,
+		bus:    b,
+	}
+
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Publish delivers an event to every matching subscription without blocking
+func (b *Bus) Publish(e Event) {
+	if counter, ok := b.published[e.Type]; ok {
+		counter.Add(1)
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs {
+		if sub.filter.matches(e) {
+			sub.offer(e)
+		}
+	}
+}
+
+// Stats returns event counters of the bus
+func (b *Bus) Stats() Stats {
+	stats := Stats{
+		Published:     make(map[Type]int64, len(b.published)),
+		Subscriptions: make([]SubscriptionStats, 0),
+	}
+
+	for t, counter := range b.published {
+		stats.Published[t] = counter.Load()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs {
+		stats.Subscriptions = append(stats.Subscriptions, SubscriptionStats{
+			Name:      sub.name,
+			Policy:    sub.policy,
+			Buffer:    cap(sub.ch),
+			Queued:    len(sub.ch),
+			Delivered: sub.delivered.Load(),
+			Dropped:   sub.dropped.Load(),
+		})
+	}
+
+	return stats
+}
+
+// Dropped returns how many events the subscription has lost to its drop policy
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close removes the subscription from its bus and closes C
+func (s *Subscription) Close() {
+	b := s.bus
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, sub := range b.subs {
+		if sub == s {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			close(s.ch)
+			return
+		}
+	}
+}
+
+// offer buffers an event, applying the drop policy when the buffer is full
+// (caller must hold the bus read lock, so the channel is open)
+func (s *Subscription) offer(e Event) {
+	for {
+		select {
+		case s.ch <- e:
+			s.delivered.Add(1)
+			return
+		default:
+		}
+
+		if s.policy == DropNewest {
+			s.dropped.Add(1)
+			return
+		}
+
+		// Make room by discarding the oldest event, then retry
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+	}
+}
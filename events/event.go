@@ -0,0 +1,35 @@
+// Package events is an in-process publish/subscribe bus for market data
+// changes. Publishing never blocks: each subscriber has a bounded buffer and
+// a policy deciding what is dropped when it falls behind.
+package events
+
+import (
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// Type identifies what changed
+type Type string
+
+// Event types
+const (
+	OrderbookUpdated    Type = "orderbook_updated"
+	TradeAppended       Type = "trade_appended"
+	TradesReplaced      Type = "trades_replaced"
+	PriceHistoryUpdated Type = "price_history_updated"
+	AnalyticsRecomputed Type = "analytics_recomputed"
+)
+
+// Types lists every event type
+var Types = []Type{OrderbookUpdated, TradeAppended, TradesReplaced, PriceHistoryUpdated, AnalyticsRecomputed}
+
+// Event is a change to the cached data of one market. Payload fields are set
+// according to Type and must not be modified by subscribers.
+type Event struct {
+	Type      Type                    `json:"type"`
+	MarketID  string                  `json:"market_id"`
+	Time      time.Time               `json:"time"`
+	Trade     *models.Trade           `json:"trade,omitempty"`     // TradeAppended
+	Analytics *models.MarketAnalytics `json:"analytics,omitempty"` // AnalyticsRecomputed
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"strconv"
+
 	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/billing"
 	"github.com/daiwikmh/origami/models"
 	"github.com/gin-gonic/gin"
 )
@@ -16,8 +19,13 @@ func InitAdminHandlers(ks *auth.KeyStore) {
 // GenerateAPIKey creates a new API key
 func GenerateAPIKey(c *gin.Context) {
 	var req struct {
-		Name      string `json:"name" binding:"required"`
-		RateLimit int    `json:"rate_limit"`
+		Name           string   `json:"name" binding:"required"`
+		RateLimit      int      `json:"rate_limit"`
+		AllowedIPs     []string `json:"allowed_ips"`
+		AllowedOrigins []string `json:"allowed_origins"`
+		Scopes         []string `json:"scopes"`
+		Org            string   `json:"org"`
+		Plan           string   `json:"plan"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -30,14 +38,41 @@ func GenerateAPIKey(c *gin.Context) {
 		req.RateLimit = 100
 	}
 
-	apiKey := keyStore.GenerateKey(req.Name, req.RateLimit)
+	if _, exists := billing.LookupPlan(req.Plan); !exists {
+		c.JSON(400, gin.H{"error": "invalid request", "details": "unknown plan: " + req.Plan})
+		return
+	}
+
+	apiKey, err := keyStore.GenerateKey(req.Name, req.RateLimit, auth.KeyOptions{
+		AllowedIPs:     req.AllowedIPs,
+		AllowedOrigins: req.AllowedOrigins,
+		Scopes:         req.Scopes,
+		Org:            req.Org,
+		Plan:           req.Plan,
+	})
+	switch err {
+	case nil:
+	case auth.ErrRegistryUnavailable:
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
 
 	c.JSON(201, gin.H{
-		"api_key":    apiKey.Key,
-		"name":       apiKey.Name,
-		"rate_limit": apiKey.RateLimit,
-		"created_at": apiKey.CreatedAt,
-		"message":    "API key created successfully. Store it securely - it won't be shown again.",
+		"id":              apiKey.ID,
+		"api_key":         apiKey.Key,
+		"signing_secret":  apiKey.SigningSecret,
+		"name":            apiKey.Name,
+		"rate_limit":      apiKey.RateLimit,
+		"allowed_ips":     apiKey.AllowedIPs,
+		"allowed_origins": apiKey.AllowedOrigins,
+		"scopes":          apiKey.Scopes,
+		"org":             apiKey.Org,
+		"plan":            apiKey.Plan,
+		"created_at":      apiKey.CreatedAt,
+		"message":         "API key created successfully. Store it securely - it won't be shown again.",
 	})
 }
 
@@ -47,14 +82,26 @@ func ListAPIKeys(c *gin.Context) {
 
 	result := make([]gin.H, 0, len(keys))
 	for _, key := range keys {
+		var requestCount int64
+		if usage, exists := keyStore.GetKeyUsageStats(key.Key); exists {
+			requestCount = usage.RequestCount
+		}
+
 		result = append(result, gin.H{
-			"key_preview":   maskKey(key.Key),
-			"name":          key.Name,
-			"created_at":    key.CreatedAt,
-			"last_used_at":  key.LastUsedAt,
-			"request_count": key.RequestCount,
-			"rate_limit":    key.RateLimit,
-			"is_active":     key.IsActive,
+			"id":              key.ID,
+			"key_preview":     maskKey(key.Key),
+			"name":            key.Name,
+			"created_at":      key.CreatedAt,
+			"last_used_at":    key.LastUsedAt,
+			"request_count":   requestCount,
+			"rate_limit":      key.RateLimit,
+			"is_active":       key.IsActive,
+			"allowed_ips":     key.AllowedIPs,
+			"allowed_origins": key.AllowedOrigins,
+			"scopes":          key.Scopes,
+			"org":             key.Org,
+			"plan":            key.Plan,
+			"enforcement":     keyStore.ActiveEnforcement(key.Key),
 		})
 	}
 
@@ -75,15 +122,90 @@ func RevokeAPIKey(c *gin.Context) {
 		return
 	}
 
-	success := keyStore.RevokeKey(req.Key)
-	if !success {
+	switch err := keyStore.RevokeKey(req.Key); err {
+	case nil:
+	case auth.ErrKeyNotFound:
 		c.JSON(404, gin.H{"error": "api key not found"})
 		return
+	default:
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(200, gin.H{"message": "API key revoked successfully"})
 }
 
+// SetKeyRestrictions updates the IP allowlist and allowed origins of a key
+func SetKeyRestrictions(c *gin.Context) {
+	var req struct {
+		Key            string   `json:"key" binding:"required"`
+		AllowedIPs     []string `json:"allowed_ips"`
+		AllowedOrigins []string `json:"allowed_origins"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	switch err := keyStore.SetKeyRestrictions(req.Key, req.AllowedIPs, req.AllowedOrigins); err {
+	case nil:
+	case auth.ErrKeyNotFound:
+		c.JSON(404, gin.H{"error": "api key not found"})
+		return
+	case auth.ErrRegistryUnavailable:
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "API key restrictions updated successfully"})
+}
+
+// ClearKeyEnforcement lifts an automatic throttle or suspension from a key
+func ClearKeyEnforcement(c *gin.Context) {
+	var req struct {
+		Key string `json:"key" binding:"required"` // Key or key ID
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	switch err := keyStore.ClearEnforcement(req.Key, auth.ActorAdmin); err {
+	case nil:
+	case auth.ErrKeyNotFound:
+		c.JSON(404, gin.H{"error": "api key not found"})
+		return
+	default:
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "API key enforcement cleared successfully"})
+}
+
+// GetAuditLog returns recent key management events, newest first
+func GetAuditLog(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	entries := keyStore.Audit().List(c.Query("key_id"), limit)
+
+	c.JSON(200, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
 // GetUsageStats returns usage statistics
 func GetUsageStats(c *gin.Context) {
 	stats := keyStore.GetUsageStats()
@@ -102,14 +224,22 @@ func GetKeyUsage(c *gin.Context) {
 	keyStr := apiKey.(string)
 
 	// Get usage stats
-	stats := keyStore.GetUsageStats()
-	keyStats, exists := stats.KeyStats[keyStr]
+	keyStats, exists := keyStore.GetKeyUsageStats(keyStr)
 	if !exists {
 		c.JSON(404, gin.H{"error": "usage data not found"})
 		return
 	}
 
-	c.JSON(200, keyStats)
+	// Estimate from the stored key, access token claims don't carry billing details
+	var estimate *models.Statement
+	if key, found := keyStore.ValidateKeyID(keyStats.ID); found && billingService != nil {
+		estimate = billingService.Estimate(key)
+	}
+
+	c.JSON(200, struct {
+		*models.KeyUsageStats
+		BillingEstimate *models.Statement `json:"billing_estimate,omitempty"`
+	}{keyStats, estimate})
 }
 
 // GetRateLimitInfo returns rate limit info for current key
@@ -122,10 +252,15 @@ func GetRateLimitInfo(c *gin.Context) {
 
 	key := apiKeyObj.(*models.APIKey)
 
+	var requestCount int64
+	if usage, exists := keyStore.GetKeyUsageStats(c.GetString("api_key")); exists {
+		requestCount = usage.RequestCount
+	}
+
 	c.JSON(200, gin.H{
 		"rate_limit":    key.RateLimit,
 		"window":        "1 minute",
-		"request_count": key.RequestCount,
+		"request_count": requestCount,
 	})
 }
 
@@ -165,7 +300,25 @@ func GetSystemInfo(c *gin.Context) {
 		{
 			"path":        "/origami/markets/:id/liquidity",
 			"method":      "GET",
-			"description": "Get liquidity metrics for a market",
+			"description": "Get liquidity score and its components for a market",
+		},
+		{
+			"path":        "/origami/markets/:id/trades",
+			"method":      "GET",
+			"description": "Get recent trades for a market",
+			"params":      "?from=&to=&side=buy|sell&min_notional=&limit=100&cursor=",
+		},
+		{
+			"path":        "/origami/markets/:id/flow",
+			"method":      "GET",
+			"description": "Get order flow and taker imbalance for a market",
+			"params":      "?window=5m|15m|1h|4h|24h",
+		},
+		{
+			"path":        "/origami/markets/:id/impact",
+			"method":      "GET",
+			"description": "Simulate price impact and slippage of a market order",
+			"params":      "?side=buy|sell&quantity=|notional=",
 		},
 		{
 			"path":        "/origami/markets/:id/analytics",
@@ -175,18 +328,37 @@ func GetSystemInfo(c *gin.Context) {
 		{
 			"path":        "/origami/markets/:id/volatility",
 			"method":      "GET",
-			"description": "Get volatility indicator for a market",
+			"description": "Get annualized volatility for a market",
+			"params":      "?estimator=realized|parkinson|garman_klass&window=1h|4h|24h|7d|30d",
 		},
 		{
 			"path":        "/origami/markets/:id/depth",
 			"method":      "GET",
 			"description": "Get orderbook depth for a market",
+			"params":      "?bands=0.5,1,2,5&ladder=true",
+		},
+		{
+			"path":        "/origami/markets/:id/candles",
+			"method":      "GET",
+			"description": "Get OHLCV candles for a market",
+			"params":      "?interval=1m|5m|15m|1h|4h|1d&from=&to=",
+		},
+		{
+			"path":        "/origami/markets/:id/indicators",
+			"method":      "GET",
+			"description": "Get technical indicators for a market",
+			"params":      "?interval=1m|5m|15m|1h|4h|1d&set=rsi14,ema20,macd,bb20,atr14&series=0",
+		},
+		{
+			"path":        "/origami/markets/:id/changes",
+			"method":      "GET",
+			"description": "Get price changes over 5m, 1h, 4h, 24h and 7d",
 		},
 		{
 			"path":        "/origami/signals/trending",
 			"method":      "GET",
-			"description": "Get trending markets",
-			"params":      "?limit=10",
+			"description": "Get trending markets with score breakdowns",
+			"params":      "?limit=10&weights=volume:0.4,volatility:0.3,price_change:0.3&method=percentile|zscore",
 		},
 		{
 			"path":        "/origami/signals/hot",
@@ -206,6 +378,12 @@ func GetSystemInfo(c *gin.Context) {
 			"description": "Get volume leaders",
 			"params":      "?limit=10",
 		},
+		{
+			"path":        "/origami/token",
+			"method":      "POST",
+			"description": "Exchange an API key for a short-lived access token",
+			"params":      "{\"ttl_seconds\": 900}",
+		},
 		{
 			"path":        "/origami/nft/verify/:address",
 			"method":      "GET",
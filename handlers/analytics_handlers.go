@@ -1,10 +1,25 @@
 package handlers
 
 import (
+	"strconv"
+	"time"
+
+	"github.com/daiwikmh/origami/config"
+	"github.com/daiwikmh/origami/models"
 	"github.com/daiwikmh/origami/services"
 	"github.com/gin-gonic/gin"
 )
 
+// defaultCandleBars is how many bars are returned when from is omitted
+const defaultCandleBars = 200
+
+var analyticsConfig *config.Config
+
+// InitAnalyticsHandlers initializes analytics handlers with runtime settings
+func InitAnalyticsHandlers(cfg *config.Config) {
+	analyticsConfig = cfg
+}
+
 // GetMarketAnalytics returns comprehensive analytics for a market
 func GetMarketAnalytics(c *gin.Context) {
 	marketID := c.Param("id")
@@ -18,32 +33,193 @@ func GetMarketAnalytics(c *gin.Context) {
 	c.JSON(200, analytics)
 }
 
-// GetVolatility returns volatility metric for a market
+// GetVolatility returns the annualized volatility of a market over a window
 func GetVolatility(c *gin.Context) {
 	marketID := c.Param("id")
+	estimator := c.DefaultQuery("estimator", services.EstimatorRealized)
+	window := c.DefaultQuery("window", "24h")
 
-	analytics := services.GetMarketAnalytics(marketID)
-	if analytics == nil {
+	estimate, err := services.EstimateVolatility(marketID, estimator, window)
+	switch err {
+	case nil:
+	case services.ErrUnknownEstimator:
+		c.JSON(400, gin.H{"error": "estimator must be one of realized, parkinson, garman_klass"})
+		return
+	case services.ErrUnknownWindow:
+		c.JSON(400, gin.H{"error": "window must be one of 1h, 4h, 24h, 7d, 30d"})
+		return
+	case services.ErrMarketNotFound:
 		c.JSON(404, gin.H{"error": "Market not found"})
 		return
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, estimate)
+}
+
+// GetOrderbookDepth returns detailed orderbook depth metrics. Custom bands
+// or a ladder are computed from the current orderbook rather than analytics.
+func GetOrderbookDepth(c *gin.Context) {
+	marketID := c.Param("id")
+	bandsParam := c.Query("bands")
+	ladder := c.Query("ladder") == "true"
+
+	if bandsParam == "" && !ladder {
+		analytics := services.GetMarketAnalytics(marketID)
+		if analytics == nil || analytics.OrderbookDepth == nil {
+			c.JSON(404, gin.H{"error": "Market not found or orderbook unavailable"})
+			return
+		}
+
+		c.JSON(200, models.DepthChart{OrderbookDepth: analytics.OrderbookDepth, Freshness: analytics.Freshness})
+		return
+	}
+
+	bands := services.DefaultDepthBands
+	if bandsParam != "" {
+		var err error
+		if bands, err = services.ParseDepthBands(bandsParam); err != nil {
+			c.JSON(400, gin.H{"error": "bands must be up to 10 comma-separated percentages above 0 and at most 50"})
+			return
+		}
+	}
+
+	chart, err := services.GetDepthChart(marketID, bands, ladder)
+	switch err {
+	case nil:
+	case services.ErrMarketNotFound, services.ErrOrderbookUnavailable:
+		c.JSON(404, gin.H{"error": "Market not found or orderbook unavailable"})
+		return
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, chart)
+}
+
+// GetCandles returns OHLCV bars for a market
+func GetCandles(c *gin.Context) {
+	marketID := c.Param("id")
+	interval := c.DefaultQuery("interval", "1h")
+
+	width, exists := services.CandleIntervals[interval]
+	if !exists {
+		c.JSON(400, gin.H{"error": "interval must be one of 1m, 5m, 15m, 1h, 4h, 1d"})
+		return
+	}
+
+	maxBars := analyticsConfig.CandlesMaxBars
+
+	to, err := parseTime(c.Query("to"), time.Now())
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, err := parseTime(c.Query("from"), to.Add(-time.Duration(min(defaultCandleBars, maxBars)-1)*width))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if from.After(to) {
+		c.JSON(400, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	if bars := int(to.Sub(from.UTC().Truncate(width))/width) + 1; bars > maxBars {
+		c.JSON(400, gin.H{"error": "requested range exceeds the maximum number of bars", "max_bars": maxBars})
+		return
+	}
+
+	candles, err := services.GetCandles(marketID, interval, from, to)
+	switch err {
+	case nil:
+	case services.ErrMarketNotFound:
+		c.JSON(404, gin.H{"error": "Market not found"})
+		return
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(200, gin.H{
-		"market_id":  marketID,
-		"volatility": analytics.Volatility,
-		"timestamp":  analytics.Timestamp,
+		"market_id": marketID,
+		"interval":  interval,
+		"from":      from.UTC(),
+		"to":        to.UTC(),
+		"candles":   candles,
+		"count":     len(candles),
 	})
 }
 
-// GetOrderbookDepth returns detailed orderbook depth metrics
-func GetOrderbookDepth(c *gin.Context) {
+// GetIndicators returns technical indicators of a market computed from its candles
+func GetIndicators(c *gin.Context) {
+	marketID := c.Param("id")
+	interval := c.DefaultQuery("interval", "1h")
+
+	if _, exists := services.CandleIntervals[interval]; !exists {
+		c.JSON(400, gin.H{"error": "interval must be one of 1m, 5m, 15m, 1h, 4h, 1d"})
+		return
+	}
+
+	specs, err := services.ParseIndicatorSet(c.DefaultQuery("set", "rsi14,ema20,macd,bb20,atr14"))
+	switch err {
+	case nil:
+	case services.ErrUnknownIndicator:
+		c.JSON(400, gin.H{"error": "set must list indicators among rsiN, emaN, macd, bbN and atrN, with N from 2 to 200"})
+		return
+	case services.ErrTooManyIndicators:
+		c.JSON(400, gin.H{"error": "set must list at most 10 indicators"})
+		return
+	}
+	if len(specs) == 0 {
+		c.JSON(400, gin.H{"error": "set must list at least one indicator"})
+		return
+	}
+
+	series, err := strconv.Atoi(c.DefaultQuery("series", "0"))
+	if err != nil || series < 0 {
+		c.JSON(400, gin.H{"error": "series must be a non-negative number of values"})
+		return
+	}
+	if series > 500 {
+		series = 500
+	}
+
+	result, err := services.GetIndicators(marketID, interval, specs, series)
+	switch err {
+	case nil:
+	case services.ErrMarketNotFound:
+		c.JSON(404, gin.H{"error": "Market not found"})
+		return
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+// GetPriceChanges returns the price change of a market over each horizon
+func GetPriceChanges(c *gin.Context) {
 	marketID := c.Param("id")
 
 	analytics := services.GetMarketAnalytics(marketID)
-	if analytics == nil || analytics.OrderbookDepth == nil {
-		c.JSON(404, gin.H{"error": "Market not found or orderbook unavailable"})
+	if analytics == nil {
+		c.JSON(404, gin.H{"error": "Market not found or analytics unavailable"})
 		return
 	}
 
-	c.JSON(200, analytics.OrderbookDepth)
+	c.JSON(200, gin.H{
+		"market_id":     marketID,
+		"current_price": analytics.CurrentPrice,
+		"changes":       analytics.PriceChanges,
+		"data_as_of":    analytics.DataAsOf,
+		"age_ms":        analytics.AgeMs,
+		"stale":         analytics.Stale,
+	})
 }
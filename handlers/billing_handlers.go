@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+
+	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/billing"
+	"github.com/gin-gonic/gin"
+)
+
+var billingService *billing.Service
+
+// InitBillingHandlers initializes billing handlers with the billing service
+func InitBillingHandlers(svc *billing.Service) {
+	billingService = svc
+}
+
+// GetBillingStatements returns the statements of a period as JSON or CSV
+func GetBillingStatements(c *gin.Context) {
+	groupBy := c.DefaultQuery("group", billing.GroupByKey)
+	if groupBy != billing.GroupByKey && groupBy != billing.GroupByOrg {
+		c.JSON(400, gin.H{"error": "group must be 'key' or 'org'"})
+		return
+	}
+
+	statements, err := billingService.Statements(c.Param("period"), groupBy)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		var buf bytes.Buffer
+		if err := billing.WriteCSV(&buf, statements, c.Query("detail") == "lines"); err != nil {
+			c.JSON(500, gin.H{"error": "failed to export statements"})
+			return
+		}
+
+		filename := "origami-billing-" + c.Param("period") + ".csv"
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		c.Data(200, "text/csv; charset=utf-8", buf.Bytes())
+	case "json":
+		c.JSON(200, gin.H{
+			"statements": statements,
+			"count":      len(statements),
+		})
+	default:
+		c.JSON(400, gin.H{"error": "format must be 'json' or 'csv'"})
+	}
+}
+
+// GetBillingPlans returns the pricing catalog and endpoint weights
+func GetBillingPlans(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"plans":            billing.Plans,
+		"default_plan":     billing.DefaultPlan,
+		"endpoint_weights": billing.EndpointWeights,
+		"default_weight":   1,
+		"currency":         billing.Currency,
+	})
+}
+
+// SetKeyBilling assigns the organization and plan a key is billed under
+func SetKeyBilling(c *gin.Context) {
+	var req struct {
+		Key  string `json:"key" binding:"required"` // Key or key ID
+		Org  string `json:"org"`
+		Plan string `json:"plan"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	if _, exists := billing.LookupPlan(req.Plan); !exists {
+		c.JSON(400, gin.H{"error": "unknown plan: " + req.Plan})
+		return
+	}
+
+	switch err := keyStore.SetKeyBilling(req.Key, req.Org, req.Plan); err {
+	case nil:
+	case auth.ErrKeyNotFound:
+		c.JSON(404, gin.H{"error": "api key not found"})
+		return
+	default:
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "API key billing updated successfully"})
+}
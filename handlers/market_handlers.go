@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"math"
 	"strconv"
+	"time"
 
 	"github.com/daiwikmh/origami/services"
 	"github.com/gin-gonic/gin"
@@ -42,33 +44,29 @@ func GetMarketSummary(c *gin.Context) {
 	c.JSON(200, result)
 }
 
-// Liquidity endpoint
+// GetLiquidity returns the liquidity score of a market with its components
 func GetLiquidity(c *gin.Context) {
 	id := c.Param("id")
 
-	ob, err := services.GetOrderbook(id)
-	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	analytics := services.GetMarketAnalytics(id)
+	if analytics == nil || analytics.Liquidity == nil {
+		c.JSON(404, gin.H{"error": "Market not found or orderbook unavailable"})
 		return
 	}
 
-	// Calculate real liquidity from orderbook depth
-	liquidity := services.CalculateRealLiquidity(ob)
-	depth := services.CalculateOrderbookDepth(ob)
-
-	response := gin.H{
+	c.JSON(200, gin.H{
 		"market_id":       id,
-		"liquidity_score": liquidity,
-	}
-
-	if depth != nil {
-		response["orderbook_depth"] = depth
-	}
-
-	c.JSON(200, response)
+		"liquidity_score": analytics.LiquidityScore,
+		"liquidity":       analytics.Liquidity,
+		"orderbook_depth": analytics.OrderbookDepth,
+		"data_as_of":      analytics.DataAsOf,
+		"age_ms":          analytics.AgeMs,
+		"stale":           analytics.Stale,
+	})
 }
 
-// Trending markets (using real analytics)
+// GetTrending returns markets ranked by trending score with the breakdown of
+// each score. Weights and method default to the server configuration.
 func GetTrending(c *gin.Context) {
 	limitStr := c.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
@@ -79,10 +77,163 @@ func GetTrending(c *gin.Context) {
 		limit = 50
 	}
 
-	markets := services.GetTopMarkets("trending", limit)
+	weights, method := services.DefaultTrending()
 
-	c.JSON(200, gin.H{
+	if value := c.Query("weights"); value != "" {
+		if weights, err = services.ParseTrendingWeights(value); err != nil {
+			c.JSON(400, gin.H{"error": "weights must be name:weight pairs of volume, volatility and price_change, e.g. volume:0.5,volatility:0.5"})
+			return
+		}
+	}
+	if value := c.Query("method"); value != "" {
+		if method, err = services.ParseTrendingMethod(value); err != nil {
+			c.JSON(400, gin.H{"error": "method must be percentile or zscore"})
+			return
+		}
+	}
+
+	markets := services.GetTrendingMarkets(limit, weights, method)
+
+	response := gin.H{
 		"markets": markets,
 		"count":   len(markets),
+		"weights": weights,
+		"method":  method,
+	}
+
+	setFreshness(response, services.SummarizeTrendingFreshness(markets))
+	c.JSON(200, response)
+}
+
+// GetTrades returns the trade tape of a market, newest first
+func GetTrades(c *gin.Context) {
+	marketID := c.Param("id")
+
+	filter := services.TradeFilter{
+		Side:   c.Query("side"),
+		Cursor: c.Query("cursor"),
+	}
+
+	if filter.Side != "" && filter.Side != "buy" && filter.Side != "sell" {
+		c.JSON(400, gin.H{"error": "side must be 'buy' or 'sell'"})
+		return
+	}
+
+	var err error
+	if filter.From, err = parseTime(c.Query("from"), time.Time{}); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.To, err = parseTime(c.Query("to"), time.Time{}); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if value := c.Query("min_notional"); value != "" {
+		filter.MinNotional, err = strconv.ParseFloat(value, 64)
+		if err != nil || filter.MinNotional < 0 {
+			c.JSON(400, gin.H{"error": "min_notional must be a non-negative number"})
+			return
+		}
+	}
+
+	filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	if filter.Limit > 1000 {
+		filter.Limit = 1000
+	}
+
+	trades, nextCursor, err := services.GetTradeTape(marketID, filter)
+	switch err {
+	case nil:
+	case services.ErrMarketNotFound:
+		c.JSON(404, gin.H{"error": "Market not found"})
+		return
+	case services.ErrInvalidCursor:
+		c.JSON(400, gin.H{"error": "invalid cursor"})
+		return
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"market_id": marketID,
+		"trades":    trades,
+		"count":     len(trades),
+	}
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
+	}
+
+	c.JSON(200, response)
+}
+
+// GetOrderFlow returns buy and sell flow of a market's recent trades
+func GetOrderFlow(c *gin.Context) {
+	marketID := c.Param("id")
+
+	flow, err := services.GetOrderFlow(marketID, c.DefaultQuery("window", "1h"))
+	switch err {
+	case nil:
+	case services.ErrUnknownWindow:
+		c.JSON(400, gin.H{"error": "window must be one of 5m, 15m, 1h, 4h, 24h"})
+		return
+	case services.ErrMarketNotFound:
+		c.JSON(404, gin.H{"error": "Market not found"})
+		return
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"market_id": marketID,
+		"flow":      flow,
 	})
 }
+
+// GetPriceImpact simulates a market order against the orderbook
+func GetPriceImpact(c *gin.Context) {
+	marketID := c.Param("id")
+
+	side := c.Query("side")
+	if side != "buy" && side != "sell" {
+		c.JSON(400, gin.H{"error": "side must be 'buy' or 'sell'"})
+		return
+	}
+
+	quantityStr, notionalStr := c.Query("quantity"), c.Query("notional")
+	if (quantityStr == "") == (notionalStr == "") {
+		c.JSON(400, gin.H{"error": "exactly one of quantity or notional is required"})
+		return
+	}
+
+	amount, err := strconv.ParseFloat(quantityStr+notionalStr, 64)
+	if err != nil || !(amount > 0) || math.IsInf(amount, 1) {
+		c.JSON(400, gin.H{"error": "quantity and notional must be positive numbers"})
+		return
+	}
+
+	var quantity, notional float64
+	if quantityStr != "" {
+		quantity = amount
+	} else {
+		notional = amount
+	}
+
+	impact, err := services.SimulateImpact(marketID, side, quantity, notional)
+	switch err {
+	case nil:
+	case services.ErrMarketNotFound:
+		c.JSON(404, gin.H{"error": "Market not found"})
+		return
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, impact)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/daiwikmh/origami/events"
+	"github.com/daiwikmh/origami/services"
+	"github.com/gin-gonic/gin"
+)
+
+var eventBus *events.Bus
+
+// InitMetricsHandlers initializes metrics handlers with the market event bus
+func InitMetricsHandlers(bus *events.Bus) {
+	eventBus = bus
+}
+
+// GetMetrics returns internal counters of the market data pipeline
+func GetMetrics(c *gin.Context) {
+	metrics := gin.H{
+		"fetches":   services.GetFetchStats(),
+		"analytics": services.GetComputeStats(),
+	}
+
+	if stats, ok := services.GetCacheStats(); ok {
+		metrics["cache"] = stats
+	}
+
+	if eventBus != nil {
+		metrics["events"] = eventBus.Stats()
+	}
+
+	c.JSON(200, metrics)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// parseTime parses a query timestamp given as unix seconds, unix milliseconds
+// or RFC 3339. Returns fallback when the value is empty.
+func parseTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Values this large are milliseconds
+		if n > 1e11 {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected unix seconds or RFC 3339", value)
+	}
+	return t, nil
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"strconv"
 
+	"github.com/daiwikmh/origami/models"
 	"github.com/daiwikmh/origami/services"
 	"github.com/gin-gonic/gin"
 )
@@ -21,10 +22,7 @@ func GetHotMarkets(c *gin.Context) {
 
 	markets := services.GetTopMarkets("trending", limit)
 
-	c.JSON(200, gin.H{
-		"markets": markets,
-		"count":   len(markets),
-	})
+	c.JSON(200, rankingResponse(markets))
 }
 
 // GetVolatilityRanking returns markets sorted by volatility
@@ -41,10 +39,7 @@ func GetVolatilityRanking(c *gin.Context) {
 
 	markets := services.GetTopMarkets("volatility", limit)
 
-	c.JSON(200, gin.H{
-		"markets": markets,
-		"count":   len(markets),
-	})
+	c.JSON(200, rankingResponse(markets))
 }
 
 // GetVolumeLeaders returns markets sorted by 24h volume
@@ -61,8 +56,29 @@ func GetVolumeLeaders(c *gin.Context) {
 
 	markets := services.GetTopMarkets("volume", limit)
 
-	c.JSON(200, gin.H{
+	c.JSON(200, rankingResponse(markets))
+}
+
+// rankingResponse wraps a market ranking with the freshness of its oldest entry
+func rankingResponse(markets []*models.MarketAnalytics) gin.H {
+	response := gin.H{
 		"markets": markets,
 		"count":   len(markets),
-	})
+	}
+
+	setFreshness(response, services.SummarizeFreshness(markets))
+	return response
+}
+
+// setFreshness adds the freshness of a list to its response. Without data,
+// data_as_of and age_ms are null.
+func setFreshness(response gin.H, freshness models.Freshness) {
+	response["data_as_of"] = nil
+	response["age_ms"] = nil
+	response["stale"] = freshness.Stale
+
+	if !freshness.DataAsOf.IsZero() {
+		response["data_as_of"] = freshness.DataAsOf
+		response["age_ms"] = freshness.AgeMs
+	}
 }
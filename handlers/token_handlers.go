@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/middleware"
+	"github.com/daiwikmh/origami/models"
+	"github.com/gin-gonic/gin"
+)
+
+var tokenIssuer *auth.TokenIssuer
+
+// InitTokenHandlers initializes token handlers with the access token issuer
+func InitTokenHandlers(issuer *auth.TokenIssuer) {
+	tokenIssuer = issuer
+}
+
+// IssueAccessToken exchanges the calling API key for a short-lived access token
+func IssueAccessToken(c *gin.Context) {
+	// Tokens must not be able to mint new tokens, or they would never expire
+	if c.GetString("auth_method") == middleware.AuthMethodAccessToken {
+		c.JSON(403, gin.H{"error": "access tokens can only be issued for api keys"})
+		return
+	}
+
+	var req struct {
+		TTLSeconds int `json:"ttl_seconds"`
+	}
+
+	// Body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+			return
+		}
+	}
+
+	if req.TTLSeconds < 0 {
+		c.JSON(400, gin.H{"error": "ttl_seconds must be positive"})
+		return
+	}
+
+	key := c.MustGet("api_key_obj").(*models.APIKey)
+
+	token, claims, err := tokenIssuer.Issue(key, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to issue access token"})
+		return
+	}
+
+	c.JSON(201, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   claims.ExpiresAt - claims.IssuedAt,
+		"expires_at":   time.Unix(claims.ExpiresAt, 0).UTC(),
+		"scopes":       claims.Scopes,
+		"rate_limit":   claims.RateLimit,
+		"max_ttl":      int64(tokenIssuer.MaxTTL().Seconds()),
+	})
+}
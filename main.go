@@ -11,55 +11,164 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/daiwikmh/origami/abuse"
 	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/billing"
 	"github.com/daiwikmh/origami/cache"
+	"github.com/daiwikmh/origami/config"
+	"github.com/daiwikmh/origami/counters"
+	"github.com/daiwikmh/origami/events"
 	"github.com/daiwikmh/origami/handlers"
+	"github.com/daiwikmh/origami/resp"
 	"github.com/daiwikmh/origami/services"
+	"github.com/daiwikmh/origami/tsdb"
 	"github.com/daiwikmh/origami/workers"
 )
 
 func main() {
 	log.Println("Initializing Origami API Platform...")
 
-	// Initialize API key store
-	keyStore := auth.NewKeyStore()
+	cfg := config.Load()
+
+	var redisClient *resp.Client
+	if cfg.RedisAddr != "" {
+		redisClient = resp.NewClient(cfg.RedisAddr, cfg.RedisPassword, 16, 500*time.Millisecond)
+	}
+
+	// Initialize counter store for rate limits and usage
+	var counterStore counters.Store = counters.NewMemoryStore()
+	if redisClient != nil {
+		counterStore = counters.NewFailoverStore(counters.NewRedisStore(redisClient), counterStore, 10*time.Second)
+	}
+	log.Printf("Counter store initialized (%s)", counterStore.Backend())
+
+	// Initialize API key store, shared between replicas with the counter store
+	var keyRegistry auth.KeyRegistry
+	if redisClient != nil {
+		keyRegistry = auth.NewRedisKeyRegistry(redisClient)
+	}
+	keyStore := auth.NewKeyStore(counterStore, keyRegistry, cfg.KeySyncInterval)
 	log.Println("API key store initialized")
 
 	// Print default API key for testing
-	keys := keyStore.ListKeys()
-	if len(keys) > 0 {
+	for _, key := range keyStore.ListKeys() {
+		if key.Name != auth.DefaultKeyName || !key.IsActive {
+			continue
+		}
 		fmt.Println("\n" + strings.Repeat("=", 70))
 		fmt.Println("  DEFAULT API KEY FOR TESTING")
 		fmt.Println(strings.Repeat("=", 70))
-		fmt.Printf("  Name: %s\n", keys[0].Name)
-		fmt.Printf("  Key:  %s\n", keys[0].Key)
-		fmt.Printf("  Rate Limit: %d requests/minute\n", keys[0].RateLimit)
+		fmt.Printf("  Name: %s\n", key.Name)
+		fmt.Printf("  ID:   %s\n", key.ID)
+		fmt.Printf("  Key:  %s\n", key.Key)
+		fmt.Printf("  Signing Secret: %s\n", key.SigningSecret)
+		fmt.Printf("  Rate Limit: %d requests/minute\n", key.RateLimit)
 		fmt.Println(strings.Repeat("=", 70))
 		fmt.Println()
+		break
+	}
+
+	// Initialize access token issuer
+	if cfg.JWTSecret == "" {
+		log.Println("ORIGAMI_JWT_SECRET not set, using a random secret (access tokens won't survive restarts)")
+	}
+	tokenIssuer := auth.NewTokenIssuer(cfg.JWTSecret, cfg.AccessTokenTTL, cfg.MaxAccessTokenTTL)
+	signatureVerifier := auth.NewSignatureVerifier(cfg.SignatureMaxSkew, counterStore)
+
+	// Initialize usage billing
+	billingService := billing.NewService(counterStore, keyStore)
+
+	// Initialize abuse detection
+	var abuseDetector *abuse.Detector
+	if cfg.AbuseDetection {
+		notifiers := []abuse.Notifier{abuse.LogNotifier{}}
+		if cfg.AbuseWebhookURL != "" {
+			notifiers = append(notifiers, abuse.NewWebhookNotifier(cfg.AbuseWebhookURL))
+		}
+		abuseDetector = abuse.NewDetector(abuse.DefaultConfig(), keyStore, notifiers...)
+		log.Println("Abuse detection enabled")
 	}
 
-	// Initialize cache
-	dataCache := cache.NewDataCache()
-	log.Println("Cache initialized")
+	// Initialize market data store
+	var marketStore cache.MarketStore
+	var snapshotter *cache.Snapshotter
+	switch cfg.MarketStore {
+	case "redis":
+		if redisClient == nil {
+			log.Fatal("ORIGAMI_MARKET_STORE=redis requires ORIGAMI_REDIS_ADDR")
+		}
+		marketStore = cache.NewRemoteStore(redisClient)
+	case "memory":
+		dataCache := cache.NewDataCache(int64(cfg.CacheMaxMB) << 20)
+		marketStore = dataCache
+
+		// Warm restart from the last snapshot, the shared store persists on its own
+		if cfg.SnapshotPath != "off" {
+			snapshotter = cache.NewSnapshotter(dataCache, cfg.SnapshotPath, cfg.SnapshotInterval)
+			snapshotter.Restore(cfg.SnapshotMaxAge)
+			snapshotter.Start()
+		}
+	default:
+		log.Fatalf("Unknown ORIGAMI_MARKET_STORE %q (expected memory or redis)", cfg.MarketStore)
+	}
+	log.Printf("Market store initialized (%s)", marketStore.Backend())
+
+	// Publish market data changes to in-process subscribers
+	eventBus := events.NewBus()
+	marketStore = cache.NewNotifyingStore(marketStore, eventBus)
+
+	// Initialize time-series history
+	var historyDB *tsdb.DB
+	if cfg.TSDBPath != "off" {
+		retention, err := tsdb.ParseRetention(cfg.TSDBRetention)
+		if err != nil {
+			log.Fatalf("Invalid ORIGAMI_TSDB_RETENTION: %v", err)
+		}
 
-	// Initialize services with cache
-	services.InitMarketService(dataCache)
+		historyDB, err = tsdb.Open(cfg.TSDBPath, retention)
+		if err != nil {
+			log.Fatalf("Failed to open time-series store: %v", err)
+		}
+		log.Printf("Time-series store opened at %s", cfg.TSDBPath)
+	}
+
+	// Initialize services with market store
+	services.InitMarketService(marketStore)
+	services.InitHistoryStore(historyDB)
+	services.InitAnalyticsFreshness(cfg.AnalyticsFreshFor, cfg.AnalyticsMaxAge)
+
+	trendingWeights, err := services.ParseTrendingWeights(cfg.TrendingWeights)
+	if err != nil {
+		log.Fatalf("Invalid ORIGAMI_TRENDING_WEIGHTS: %v", err)
+	}
+	trendingMethod, err := services.ParseTrendingMethod(cfg.TrendingMethod)
+	if err != nil {
+		log.Fatalf("Invalid ORIGAMI_TRENDING_METHOD: %v", err)
+	}
+	services.InitTrending(trendingWeights, trendingMethod)
 	log.Println("Services initialized")
 
 	// Initialize handlers
 	handlers.InitAdminHandlers(keyStore)
+	handlers.InitTokenHandlers(tokenIssuer)
+	handlers.InitBillingHandlers(billingService)
+	handlers.InitAnalyticsHandlers(cfg)
+	handlers.InitMetricsHandlers(eventBus)
 	log.Println("Handlers initialized")
 
 	// Start background workers
-	collector := workers.NewDataCollector(dataCache)
-	collector.Start()
+	collector := workers.NewDataCollector(marketStore, historyDB)
+	analyticsEngine := workers.NewAnalyticsEngine(marketStore, eventBus, cfg.AnalyticsDebounce, cfg.AnalyticsMinInterval)
+	if cfg.Collector {
+		analyticsEngine.Start()
+		collector.Start()
+	} else {
+		log.Println("Background collection disabled (ORIGAMI_COLLECTOR=false)")
+	}
 
 	// Setup HTTP server
-	r := SetupRouter(keyStore)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	r := SetupRouter(cfg, marketStore, counterStore, keyStore, tokenIssuer, signatureVerifier, abuseDetector, billingService)
+	port := cfg.Port
 
 	srv := &http.Server{
 		Addr:         ":" + port,
@@ -71,16 +180,27 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		log.Println("Starting server on :8080...")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Starting server on :%s...", port)
+
+		var err error
+		if cfg.TLSEnabled() {
+			err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed: %v", err)
 		}
 	}()
 
-	log.Println("Server started successfully on :8080")
+	log.Printf("Server started successfully on :%s", port)
 	log.Println("API is ready to accept requests")
-	log.Println("\nAccess the dashboard at: http://localhost:8080/dashboard")
-	log.Println("Test API endpoints at: http://localhost:8080/test")
+	if cfg.EnableDashboard {
+		log.Printf("\nAccess the dashboard at: http://localhost:%s/", port)
+		log.Printf("Test API endpoints at: http://localhost:%s/test", port)
+	} else {
+		log.Println("Dashboard and API tester disabled (ORIGAMI_ENABLE_DASHBOARD=false)")
+	}
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -94,7 +214,20 @@ func main() {
 	defer cancel()
 
 	// Stop background workers
-	collector.Stop()
+	if cfg.Collector {
+		collector.Stop()
+		analyticsEngine.Stop()
+	}
+
+	// Persist collected state for the next start
+	if snapshotter != nil {
+		snapshotter.Stop()
+	}
+	if historyDB != nil {
+		if err := historyDB.Close(); err != nil {
+			log.Printf("Error closing time-series store: %v", err)
+		}
+	}
 
 	// Shutdown HTTP server
 	if err := srv.Shutdown(ctx); err != nil {
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/daiwikmh/origami/abuse"
+	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/models"
+	"github.com/gin-gonic/gin"
+)
+
+// AbuseMonitor rejects suspended keys and reports every response to the
+// abuse detector. Must run after APIKeyAuth and before RateLimiter so that
+// rate limited requests are observed.
+func AbuseMonitor(keyStore *auth.KeyStore, detector *abuse.Detector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyObj, exists := c.Get("api_key_obj")
+		if !exists {
+			c.JSON(500, gin.H{"error": "internal server error"})
+			c.Abort()
+			return
+		}
+
+		key := keyObj.(*models.APIKey)
+
+		enforcement := keyStore.ActiveEnforcement(key.ID)
+		if enforcement != nil && enforcement.Action == models.EnforcementSuspended {
+			keyStore.TrackRejection(key.ID, auth.RejectKeySuspended)
+			c.JSON(403, gin.H{
+				"error":  "api key suspended",
+				"reason": enforcement.Reason,
+				"until":  enforcement.Until,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		if detector != nil {
+			// Access tokens are handed to end users on any network, so their
+			// addresses say nothing about a leaked key
+			clientIP := c.ClientIP()
+			if c.GetString("auth_method") == AuthMethodAccessToken {
+				clientIP = ""
+			}
+			detector.Observe(key, clientIP, c.Writer.Status())
+		}
+	}
+}
@@ -1,46 +1,141 @@
 package middleware
 
 import (
+	"bytes"
+	"io"
 	"strings"
 
 	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/models"
 	"github.com/gin-gonic/gin"
 )
 
-// APIKeyAuth validates API key from Authorization header
-func APIKeyAuth(keyStore *auth.KeyStore) gin.HandlerFunc {
+// Authentication methods stored in the context under "auth_method"
+const (
+	AuthMethodAPIKey      = "api_key"
+	AuthMethodAccessToken = "access_token"
+	AuthMethodSignature   = "signature"
+)
+
+// maxSignedBodySize caps how much of a signed request body is buffered for hashing
+const maxSignedBodySize = 1 << 20
+
+// APIKeyAuth authenticates a request using one of:
+//   - an API key in the Authorization header
+//   - an access token in the Authorization header, verified statelessly
+//   - an HMAC signature in the X-Origami-* headers
+func APIKeyAuth(keyStore *auth.KeyStore, tokens *auth.TokenIssuer, signatures *auth.SignatureVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 
-		// Check if Authorization header is present
-		if authHeader == "" {
+		var (
+			identity string
+			key      *models.APIKey
+			method   string
+			ok       bool
+		)
+
+		switch {
+		case authHeader != "":
+			identity, key, method, ok = authenticateBearer(c, authHeader, keyStore, tokens)
+		case c.GetHeader(auth.HeaderSignature) != "":
+			identity, key, method, ok = authenticateSignature(c, keyStore, signatures)
+		default:
 			c.JSON(401, gin.H{"error": "missing api key"})
 			c.Abort()
 			return
 		}
 
-		// Extract Bearer token
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(401, gin.H{"error": "invalid api key"})
+		if !ok {
+			return
+		}
+
+		if scope := auth.ScopeForPath(c.FullPath()); !auth.HasScope(key, scope) {
+			c.JSON(403, gin.H{"error": "insufficient scope", "required_scope": scope})
 			c.Abort()
 			return
 		}
 
-		apiKey := parts[1]
+		// Store key in context for later use. "api_key" holds the key itself
+		// for API key and signature auth, and the key ID for access tokens.
+		c.Set("api_key", identity)
+		c.Set("api_key_obj", key)
+		c.Set("auth_method", method)
+
+		c.Next()
+	}
+}
+
+// authenticateBearer validates an API key or access token. Aborts on failure.
+func authenticateBearer(c *gin.Context, authHeader string, keyStore *auth.KeyStore, tokens *auth.TokenIssuer) (string, *models.APIKey, string, bool) {
+	// Extract Bearer token
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.JSON(401, gin.H{"error": "invalid api key"})
+		c.Abort()
+		return "", nil, "", false
+	}
+
+	credential := parts[1]
 
+	if strings.HasPrefix(credential, "og_") {
 		// Validate key
-		key, valid := keyStore.ValidateKey(apiKey)
+		apiKey, valid := keyStore.ValidateKey(credential)
 		if !valid {
 			c.JSON(401, gin.H{"error": "invalid api key"})
 			c.Abort()
-			return
+			return "", nil, "", false
 		}
 
-		// Store key in context for later use
-		c.Set("api_key", apiKey)
-		c.Set("api_key_obj", key)
+		return credential, apiKey, AuthMethodAPIKey, true
+	}
 
-		c.Next()
+	claims, err := tokens.Verify(credential)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "invalid access token", "details": err.Error()})
+		c.Abort()
+		return "", nil, "", false
+	}
+
+	return claims.Subject, claims.APIKey(), AuthMethodAccessToken, true
+}
+
+// authenticateSignature validates an HMAC-signed request. Aborts on failure.
+func authenticateSignature(c *gin.Context, keyStore *auth.KeyStore, signatures *auth.SignatureVerifier) (string, *models.APIKey, string, bool) {
+	keyID := c.GetHeader(auth.HeaderKeyID)
+
+	apiKey, valid := keyStore.ValidateKeyID(keyID)
+	if !valid {
+		c.JSON(401, gin.H{"error": "invalid api key"})
+		c.Abort()
+		return "", nil, "", false
+	}
+
+	// Buffer the body for hashing and restore it for handlers
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBodySize+1))
+	if err != nil || len(body) > maxSignedBodySize {
+		c.JSON(413, gin.H{"error": "request body too large to verify"})
+		c.Abort()
+		return "", nil, "", false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	request := &auth.SignedRequest{
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.EscapedPath(),
+		RawQuery:  c.Request.URL.RawQuery,
+		Body:      body,
+		KeyID:     keyID,
+		Timestamp: c.GetHeader(auth.HeaderTimestamp),
+		Nonce:     c.GetHeader(auth.HeaderNonce),
+		Signature: c.GetHeader(auth.HeaderSignature),
+	}
+
+	if err := signatures.Verify(request, apiKey.SigningSecret); err != nil {
+		c.JSON(401, gin.H{"error": "invalid request signature", "details": err.Error()})
+		c.Abort()
+		return "", nil, "", false
 	}
+
+	return apiKey.Key, apiKey, AuthMethodSignature, true
 }
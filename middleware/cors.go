@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	corsAllowMethods = "GET, POST, OPTIONS"
+	corsAllowHeaders = "Authorization, Content-Type"
+	corsMaxAge       = "600"
+)
+
+// CORSPreflight answers OPTIONS preflight requests with the allowed methods
+// and headers. Preflights carry no credentials, so the origin can't be checked
+// against a key here; CORS and KeyRestrictions enforce it per key on the
+// actual request.
+func CORSPreflight() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if c.Request.Method != "OPTIONS" || origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+		c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+		c.Header("Access-Control-Max-Age", corsMaxAge)
+		c.Header("Vary", "Origin")
+		c.AbortWithStatus(204)
+	}
+}
+
+// CORS echoes the request origin only when the calling key allows it.
+// Must run after APIKeyAuth.
+func CORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Origin")
+
+		origin := c.GetHeader("Origin")
+		keyObj, exists := c.Get("api_key_obj")
+		if origin == "" || !exists {
+			c.Next()
+			return
+		}
+
+		key := keyObj.(*models.APIKey)
+		if auth.OriginAllowed(key.AllowedOrigins, origin) {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+
+		c.Next()
+	}
+}
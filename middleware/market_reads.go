@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/daiwikmh/origami/cache"
+	"github.com/gin-gonic/gin"
+)
+
+// MarketReads marks the market of /markets/:id requests as read, so the
+// market store evicts the markets clients stopped requesting first
+func MarketReads(store cache.MarketStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		// After the handler, which may have fetched the market into the store
+		if marketID := c.Param("id"); marketID != "" {
+			store.Touch(marketID)
+		}
+	}
+}
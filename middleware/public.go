@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/daiwikmh/origami/counters"
+	"github.com/gin-gonic/gin"
+)
+
+// publicWindow is the rate limit window for unauthenticated routes
+const publicWindow = time.Minute
+
+// PublicRateLimiter enforces a per-IP rate limit on routes without an API key.
+// Counters live in the shared store, so the limit holds across replicas.
+func PublicRateLimiter(store counters.Store, limit int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count, err := store.IncrWindow("public:"+c.ClientIP(), publicWindow)
+		if err != nil {
+			// Fail open, an unavailable store must not take the site down
+			log.Printf("Public rate limit check failed: %v", err)
+			c.Next()
+			return
+		}
+
+		if count > int64(limit) {
+			retryAfter := publicWindow - time.Duration(time.Now().UnixNano()%int64(publicWindow))
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.JSON(429, gin.H{
+				"error":      "rate limit exceeded",
+				"rate_limit": limit,
+				"window":     "1 minute",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -29,13 +29,21 @@ func RateLimiter(keyStore *auth.KeyStore) gin.HandlerFunc {
 
 		key := keyObj.(*models.APIKey)
 
+		// Throttled keys get a reduced limit
+		limit := keyStore.EffectiveRateLimit(keyStr, key.RateLimit)
+
 		// Check rate limit
-		if !keyStore.CheckRateLimit(keyStr, key.RateLimit) {
-			c.JSON(429, gin.H{
-				"error":       "rate limit exceeded",
-				"rate_limit":  key.RateLimit,
-				"window":      "1 minute",
-			})
+		if !keyStore.CheckRateLimit(keyStr, limit) {
+			response := gin.H{
+				"error":      "rate limit exceeded",
+				"rate_limit": limit,
+				"window":     "1 minute",
+			}
+			if limit < key.RateLimit {
+				response["throttled"] = true
+			}
+
+			c.JSON(429, response)
 			c.Abort()
 			return
 		}
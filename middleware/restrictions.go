@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/models"
+	"github.com/gin-gonic/gin"
+)
+
+// KeyRestrictions enforces per-key IP allowlists and allowed browser origins
+func KeyRestrictions(keyStore *auth.KeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, exists := c.Get("api_key")
+		if !exists {
+			c.JSON(500, gin.H{"error": "internal server error"})
+			c.Abort()
+			return
+		}
+
+		keyStr := apiKey.(string)
+		key := c.MustGet("api_key_obj").(*models.APIKey)
+
+		if !auth.IPAllowed(key, c.ClientIP()) {
+			keyStore.TrackRejection(keyStr, auth.RejectIPNotAllowed)
+			c.JSON(403, gin.H{"error": "client ip not allowed for this api key"})
+			c.Abort()
+			return
+		}
+
+		if len(key.AllowedOrigins) > 0 {
+			origin := auth.RequestOrigin(c.GetHeader("Origin"), c.GetHeader("Referer"))
+			if origin == "" {
+				keyStore.TrackRejection(keyStr, auth.RejectOriginMissing)
+				c.JSON(403, gin.H{"error": "origin required for this api key"})
+				c.Abort()
+				return
+			}
+
+			if !auth.OriginAllowed(key.AllowedOrigins, origin) {
+				keyStore.TrackRejection(keyStr, auth.RejectOriginNotAllowed)
+				c.JSON(403, gin.H{"error": "origin not allowed for this api key"})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
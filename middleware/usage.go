@@ -2,11 +2,13 @@ package middleware
 
 import (
 	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/billing"
+	"github.com/daiwikmh/origami/models"
 	"github.com/gin-gonic/gin"
 )
 
-// UsageTracker tracks API usage per key
-func UsageTracker(keyStore *auth.KeyStore) gin.HandlerFunc {
+// UsageTracker tracks API usage per key and meters it for billing
+func UsageTracker(keyStore *auth.KeyStore, meter *billing.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get API key from context (set by auth middleware)
 		apiKey, exists := c.Get("api_key")
@@ -25,5 +27,11 @@ func UsageTracker(keyStore *auth.KeyStore) gin.HandlerFunc {
 		keyStore.TrackEndpointUsage(keyStr, endpoint)
 
 		c.Next()
+
+		// Meter for billing once the response is known, server errors are free
+		if meter != nil && c.Writer.Status() < 500 {
+			key := c.MustGet("api_key_obj").(*models.APIKey)
+			meter.Record(key.ID, endpoint)
+		}
 	}
 }
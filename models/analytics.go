@@ -4,15 +4,46 @@ import "time"
 
 // OrderbookDepth provides multi-level orderbook metrics
 type OrderbookDepth struct {
-	BidDepth5   float64 `json:"bid_depth_5"`
-	AskDepth5   float64 `json:"ask_depth_5"`
-	BidDepth10  float64 `json:"bid_depth_10"`
-	AskDepth10  float64 `json:"ask_depth_10"`
-	TotalBids   int     `json:"total_bids"`
-	TotalAsks   int     `json:"total_asks"`
-	Spread      float64 `json:"spread"`
-	SpreadBps   float64 `json:"spread_bps"`
-	MidPrice    float64 `json:"mid_price"`
+	BidDepth5  float64     `json:"bid_depth_5"`
+	AskDepth5  float64     `json:"ask_depth_5"`
+	BidDepth10 float64     `json:"bid_depth_10"`
+	AskDepth10 float64     `json:"ask_depth_10"`
+	TotalBids  int         `json:"total_bids"`
+	TotalAsks  int         `json:"total_asks"`
+	Spread     float64     `json:"spread"`
+	SpreadBps  float64     `json:"spread_bps"`
+	MidPrice   float64     `json:"mid_price"`
+	Bands      []DepthBand `json:"bands,omitempty"` // Depth within percentage bands around mid
+}
+
+// DepthBand is the resting liquidity within a percentage of mid on each side
+type DepthBand struct {
+	Percent     float64 `json:"percent"`
+	BidNotional float64 `json:"bid_notional"`
+	AskNotional float64 `json:"ask_notional"`
+	BidQuantity float64 `json:"bid_quantity"`
+	AskQuantity float64 `json:"ask_quantity"`
+}
+
+// DepthStep is one price level of a cumulative depth ladder
+type DepthStep struct {
+	Price              float64 `json:"price"`
+	Quantity           float64 `json:"quantity"`
+	CumulativeQuantity float64 `json:"cumulative_quantity"`
+	CumulativeNotional float64 `json:"cumulative_notional"`
+}
+
+// DepthLadder is the cumulative depth of each side outward from the best price
+type DepthLadder struct {
+	Bids []DepthStep `json:"bids"`
+	Asks []DepthStep `json:"asks"`
+}
+
+// DepthChart is orderbook depth with an optional ladder, as served
+type DepthChart struct {
+	*OrderbookDepth
+	Ladder *DepthLadder `json:"ladder,omitempty"`
+	Freshness
 }
 
 // MarketAnalytics contains comprehensive market metrics
@@ -24,19 +55,45 @@ type MarketAnalytics struct {
 	Volume24h        float64         `json:"volume_24h"`
 	PriceChange24h   float64         `json:"price_change_24h"`
 	PriceChange24hPct float64        `json:"price_change_24h_pct"`
+	PriceChanges     []PriceChange   `json:"price_changes,omitempty"` // 5m, 1h, 4h, 24h and 7d
 	Volatility       float64         `json:"volatility"`
-	LiquidityScore   float64         `json:"liquidity_score"`
+	LiquidityScore   float64         `json:"liquidity_score"` // Liquidity.Score
+	Liquidity        *Liquidity      `json:"liquidity,omitempty"`
 	TrendingScore    float64         `json:"trending_score"`
 	OrderbookDepth   *OrderbookDepth `json:"orderbook_depth,omitempty"`
+	OrderFlow        *OrderFlow      `json:"order_flow,omitempty"` // Last hour of cached trades, in chain units like the other fields
 	Timestamp        time.Time       `json:"timestamp"`
+	Restored         bool            `json:"restored,omitempty"` // Loaded from a snapshot, not yet recomputed
+	Freshness
+}
+
+// Freshness describes how current served data is. DataAsOf and the source
+// times are set when analytics are computed; AgeMs and Stale when served.
+type Freshness struct {
+	DataAsOf      time.Time  `json:"data_as_of"`
+	AgeMs         int64      `json:"age_ms"`
+	Stale         bool       `json:"stale"`
+	OrderbookAsOf *time.Time `json:"orderbook_as_of,omitempty"` // When the orderbook used was fetched
+	TradesAsOf    *time.Time `json:"trades_as_of,omitempty"`    // Time of the newest trade used
 }
 
 // TrendingMarket represents a market in trending rankings
 type TrendingMarket struct {
-	MarketID    string  `json:"market_id"`
-	Symbol      string  `json:"symbol"`
-	Score       float64 `json:"score"`
-	Volume24h   float64 `json:"volume_24h"`
-	Volatility  float64 `json:"volatility"`
-	PriceChange float64 `json:"price_change_pct"`
+	MarketID    string                       `json:"market_id"`
+	Symbol      string                       `json:"symbol"`
+	Score       float64                      `json:"score"`
+	Volume24h   *float64                     `json:"volume_24h"` // In USD, like the volume component; null when unavailable
+	Volatility  float64                      `json:"volatility"`
+	PriceChange float64                      `json:"price_change_pct"`
+	Components  map[string]TrendingComponent `json:"components"` // By name: volume, volatility, price_change
+	Freshness
+}
+
+// TrendingComponent is one input of a trending score
+type TrendingComponent struct {
+	Value        float64 `json:"value"`        // Raw measurement
+	Score        float64 `json:"score"`        // Percentile (0-100) or z-score across markets
+	Weight       float64 `json:"weight"`       // Share of the overall score
+	Contribution float64 `json:"contribution"`          // Score times weight
+	Unavailable  bool    `json:"unavailable,omitempty"` // Not measured for this market, its weight goes to the others
 }
@@ -4,14 +4,49 @@ import "time"
 
 // APIKey represents an API key with metadata
 type APIKey struct {
-	Key           string            `json:"key"`
-	Name          string            `json:"name"`
-	CreatedAt     time.Time         `json:"created_at"`
-	LastUsedAt    *time.Time        `json:"last_used_at,omitempty"`
-	RateLimit     int               `json:"rate_limit"`      // Requests per minute
-	RequestCount  int64             `json:"request_count"`   // Total requests made
-	IsActive      bool              `json:"is_active"`
-	EndpointUsage map[string]int64  `json:"endpoint_usage"`  // Track usage per endpoint
+	ID             string            `json:"id"`              // Public identifier, safe to embed in tokens
+	Key            string            `json:"key"`
+	SigningSecret  string            `json:"-"`               // Secret for HMAC request signing
+	Name           string            `json:"name"`
+	CreatedAt      time.Time         `json:"created_at"`
+	LastUsedAt     *time.Time        `json:"last_used_at,omitempty"`
+	RateLimit      int               `json:"rate_limit"`      // Requests per minute
+	IsActive       bool              `json:"is_active"`
+	Scopes         []string          `json:"scopes,omitempty"` // Granted scopes; empty grants all
+	Org            string            `json:"org,omitempty"`   // Organization billed for this key
+	Plan           string            `json:"plan,omitempty"`  // Billing plan name; empty uses the default plan
+	AllowedIPs     []string          `json:"allowed_ips,omitempty"`     // CIDRs or single IPs; empty allows any
+	AllowedOrigins []string          `json:"allowed_origins,omitempty"` // Origin patterns; empty allows any
+	Enforcement    *KeyEnforcement   `json:"enforcement,omitempty"`     // Active automatic throttle or suspension
+}
+
+// Enforcement actions applied to misbehaving keys
+const (
+	EnforcementThrottled = "throttled"
+	EnforcementSuspended = "suspended"
+)
+
+// KeyEnforcement records a temporary throttle or suspension of a key
+type KeyEnforcement struct {
+	Action    string    `json:"action"`               // "throttled" or "suspended"
+	Reason    string    `json:"reason"`
+	RateLimit int       `json:"rate_limit,omitempty"` // Reduced limit while throttled
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+}
+
+// Active reports whether the enforcement is still in effect
+func (e *KeyEnforcement) Active(now time.Time) bool {
+	return e != nil && now.Before(e.Until)
+}
+
+// AuditEntry records an administrative or automatic action on a key
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Action  string    `json:"action"`
+	KeyID   string    `json:"key_id,omitempty"`
+	Details string    `json:"details,omitempty"`
 }
 
 // UsageStats provides aggregated usage statistics
@@ -19,21 +54,26 @@ type UsageStats struct {
 	TotalKeys       int                        `json:"total_keys"`
 	ActiveKeys      int                        `json:"active_keys"`
 	TotalRequests   int64                      `json:"total_requests"`
+	TotalRejections int64                      `json:"total_rejections"`
+	CounterBackend  string                     `json:"counter_backend"`
 	KeyStats        map[string]*KeyUsageStats  `json:"key_stats"`
 }
 
 // KeyUsageStats provides per-key usage information
 type KeyUsageStats struct {
+	ID            string            `json:"id"`
 	Name          string            `json:"name"`
 	RequestCount  int64             `json:"request_count"`
 	LastUsedAt    *time.Time        `json:"last_used_at,omitempty"`
 	RateLimit     int               `json:"rate_limit"`
 	EndpointUsage map[string]int64  `json:"endpoint_usage"`
+	Rejections    map[string]int64  `json:"rejections"`
 	CreatedAt     time.Time         `json:"created_at"`
 }
 
 // RateLimitInfo tracks rate limiting state
 type RateLimitInfo struct {
 	WindowStart time.Time
+	Window      time.Duration
 	Count       int
 }
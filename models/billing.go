@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Plan defines monthly pricing for an account
+type Plan struct {
+	Name          string  `json:"name"`
+	MonthlyFee    float64 `json:"monthly_fee"`
+	IncludedUnits int64   `json:"included_units"` // Weighted request units included in the fee
+	OveragePer1K  float64 `json:"overage_per_1k"` // Price per 1000 units above the included amount
+}
+
+// StatementLine is the usage of one endpoint within a statement
+type StatementLine struct {
+	Endpoint string  `json:"endpoint"`
+	Requests int64   `json:"requests"`
+	Weight   float64 `json:"weight"`
+	Units    float64 `json:"units"`
+}
+
+// Statement is the bill of one account (a key or an organization) for a month
+type Statement struct {
+	Period         string           `json:"period"`       // YYYY-MM
+	AccountType    string           `json:"account_type"` // "key" or "org"
+	AccountID      string           `json:"account_id"`
+	AccountName    string           `json:"account_name"`
+	KeyIDs         []string         `json:"key_ids"`
+	Plan           string           `json:"plan"`
+	Currency       string           `json:"currency"`
+	Requests       int64            `json:"requests"`
+	Units          float64          `json:"units"`
+	IncludedUnits  int64            `json:"included_units"`
+	OverageUnits   float64          `json:"overage_units"`
+	BaseFee        float64          `json:"base_fee"`
+	OverageFee     float64          `json:"overage_fee"`
+	Total          float64          `json:"total"`
+	Lines          []*StatementLine `json:"lines"`
+	GeneratedAt    time.Time        `json:"generated_at"`
+	Final          bool             `json:"final"`                     // False while the period is still open
+	ProjectedUnits float64          `json:"projected_units,omitempty"` // Open periods: units extrapolated to month end
+	ProjectedTotal float64          `json:"projected_total,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Candle sources
+const (
+	CandleSourceTrades = "trades" // Built from executed trades
+	CandleSourceMid    = "mid"    // No trades in the interval, built from sampled mid prices
+	CandleSourceFilled = "filled" // No data in the interval, carries the previous close
+)
+
+// Candle is one OHLCV bar
+type Candle struct {
+	Time        time.Time `json:"time"` // Bar open time
+	Open        float64   `json:"open"`
+	High        float64   `json:"high"`
+	Low         float64   `json:"low"`
+	Close       float64   `json:"close"`
+	Volume      float64   `json:"volume"`       // Base quantity traded
+	QuoteVolume float64   `json:"quote_volume"` // Notional traded
+	Trades      int       `json:"trades"`
+	Source      string    `json:"source"`
+	Partial     bool      `json:"partial,omitempty"` // The bar is still open
+}
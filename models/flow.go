@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// OrderFlow summarizes the taker side of a market's trades over a window
+type OrderFlow struct {
+	Window          string    `json:"window"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	Trades          int       `json:"trades"`
+	BuyTrades       int       `json:"buy_trades"`
+	SellTrades      int       `json:"sell_trades"`
+	BuyVolume       float64   `json:"buy_volume"`  // Base quantity bought by takers
+	SellVolume      float64   `json:"sell_volume"` // Base quantity sold by takers
+	BuyNotional     float64   `json:"buy_notional"`
+	SellNotional    float64   `json:"sell_notional"`
+	AvgTradeSize    float64   `json:"avg_trade_size"` // In base quantity
+	MedianTradeSize float64   `json:"median_trade_size"`
+	VWAP            float64   `json:"vwap"`
+	CVD             float64   `json:"cvd"`             // Cumulative volume delta: buy minus sell volume
+	TakerImbalance  float64   `json:"taker_imbalance"` // (buy - sell) / (buy + sell) notional, from -1 to 1
+	Truncated       bool      `json:"truncated"`       // Older trades of the window are no longer cached
+}
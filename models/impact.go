@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// PriceImpact is the simulated execution of a market order against the orderbook
+type PriceImpact struct {
+	MarketID             string    `json:"market_id"`
+	Side                 string    `json:"side"`               // "buy" or "sell"
+	Quantity             float64   `json:"quantity,omitempty"` // Requested base quantity
+	Notional             float64   `json:"notional,omitempty"` // Requested quote amount, before fees
+	Fillable             bool      `json:"fillable"`           // The book holds enough liquidity for the whole order
+	FilledQuantity       float64   `json:"filled_quantity"`
+	FilledNotional       float64   `json:"filled_notional"`
+	LevelsConsumed       int       `json:"levels_consumed"`
+	MidPrice             float64   `json:"mid_price"`
+	BestPrice            float64   `json:"best_price"`
+	AvgPrice             float64   `json:"avg_price"`
+	WorstPrice           float64   `json:"worst_price"`
+	SlippageBps          float64   `json:"slippage_bps"`     // Average price vs mid, positive when worse
+	PriceImpactBps       float64   `json:"price_impact_bps"` // Worst price vs mid, positive when worse
+	TakerFeeRate         float64   `json:"taker_fee_rate"`
+	TakerFee             float64   `json:"taker_fee"`       // In quote tokens
+	EffectivePrice       float64   `json:"effective_price"` // Average price including the taker fee
+	EffectiveSlippageBps float64   `json:"effective_slippage_bps"`
+	OrderbookAsOf        time.Time `json:"orderbook_as_of"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// IndicatorValue is an indicator's output for one bar
+type IndicatorValue struct {
+	Time    time.Time          `json:"time"`              // Bar open time
+	Partial bool               `json:"partial,omitempty"` // The bar is still open
+	Values  map[string]float64 `json:"values"`            // Output lines, e.g. macd, signal and histogram
+}
+
+// Indicator is the latest value of one indicator and optionally its recent series
+type Indicator struct {
+	Name                string           `json:"name"` // As requested, e.g. rsi14
+	Latest              *IndicatorValue  `json:"latest"`
+	Series              []IndicatorValue `json:"series,omitempty"` // Oldest first
+	InsufficientHistory bool             `json:"insufficient_history"`
+}
+
+// IndicatorSet is a set of indicators of a market at one candle interval
+type IndicatorSet struct {
+	MarketID   string       `json:"market_id"`
+	Interval   string       `json:"interval"`
+	Bars       int          `json:"bars"`        // Candles the indicators are computed from
+	ComputedAt time.Time    `json:"computed_at"` // Results are cached briefly per interval
+	Indicators []*Indicator `json:"indicators"`
+}
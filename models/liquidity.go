@@ -0,0 +1,22 @@
+package models
+
+// LiquidityComponent is one input of the liquidity score
+type LiquidityComponent struct {
+	Value  float64 `json:"value"`  // Raw measurement
+	Score  float64 `json:"score"`  // Percentile across markets, 0-100
+	Weight float64 `json:"weight"` // Share of the overall score
+}
+
+// Liquidity is a market's liquidity score with its component breakdown.
+// Components are measured when analytics are computed and scored against
+// the other markets when served.
+type Liquidity struct {
+	Score    float64            `json:"score"`    // Weighted component scores, 0-100
+	Spread   LiquidityComponent `json:"spread"`   // Bid-ask spread in bps, tighter scores higher
+	Depth    LiquidityComponent `json:"depth"`    // USD resting within 2% of mid, both sides
+	Activity LiquidityComponent `json:"activity"` // USD traded in the last 24h
+	Universe int                `json:"universe"` // Markets scored against, including this one
+
+	QuoteUSDRate float64 `json:"quote_usd_rate"`     // USD per quote token used for depth and activity
+	Unpriced     bool    `json:"unpriced,omitempty"` // Quote token has no USD price: amounts are in quote tokens, not scored or ranked
+}
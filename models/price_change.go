@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PriceChange is the change of a market's price over a horizon, measured
+// against the price observed at the start of the horizon
+type PriceChange struct {
+	Horizon             string     `json:"horizon"`
+	Change              *float64   `json:"change"` // Null without enough history
+	ChangePct           *float64   `json:"change_pct"`
+	ReferencePrice      *float64   `json:"reference_price"`
+	ReferenceTime       *time.Time `json:"reference_time"` // When the reference price was observed
+	InsufficientHistory bool       `json:"insufficient_history"`
+}
@@ -4,19 +4,20 @@ import "time"
 
 // Trade represents a single trade execution
 type Trade struct {
-	MarketID  string
-	Price     float64
-	Quantity  float64
-	Timestamp time.Time
-	IsBuy     bool
+	TradeID   string    `json:"trade_id,omitempty"`
+	MarketID  string    `json:"market_id"`
+	Price     float64   `json:"price"`
+	Quantity  float64   `json:"quantity"`
+	Timestamp time.Time `json:"timestamp"`
+	IsBuy     bool      `json:"is_buy"`
 }
 
 // PriceHistory maintains a rolling window of prices for a market
 type PriceHistory struct {
-	MarketID string
-	Prices   []float64
-	Times    []time.Time
-	MaxSize  int
+	MarketID string      `json:"market_id"`
+	Prices   []float64   `json:"prices"`
+	Times    []time.Time `json:"times"`
+	MaxSize  int         `json:"max_size"`
 }
 
 // NewPriceHistory creates a new price history with default window size
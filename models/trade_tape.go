@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TapeTrade is a trade with human-readable price and quantity
+type TapeTrade struct {
+	TradeID   string    `json:"trade_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Side      string    `json:"side"` // "buy" or "sell"
+	Price     float64   `json:"price"`
+	Quantity  float64   `json:"quantity"`
+	Notional  float64   `json:"notional"` // Price * quantity in quote tokens
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// VolatilityEstimate is the annualized volatility of a market over a window
+type VolatilityEstimate struct {
+	MarketID            string     `json:"market_id"`
+	Estimator           string     `json:"estimator"`
+	Window              string     `json:"window"`
+	Interval            string     `json:"interval"` // Candle interval the estimate is built from
+	From                time.Time  `json:"from"`
+	To                  time.Time  `json:"to"`
+	Bars                int        `json:"bars"`       // Bars with data used
+	Volatility          *float64   `json:"volatility"` // Annualized, as a fraction (0.8 is 80%); null without enough history
+	InsufficientHistory bool       `json:"insufficient_history"`
+	DataAsOf            *time.Time `json:"data_as_of"` // End of the newest bar with data
+	AgeMs               int64      `json:"age_ms"`
+	Stale               bool       `json:"stale"` // No data within the last interval
+}
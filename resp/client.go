@@ -0,0 +1,256 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Error is an error reply returned by the server
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// ConnError is a failure to reach the server or exchange data with it, as
+// opposed to an error reply to a command
+type ConnError struct {
+	Err error
+}
+
+func (e *ConnError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnError) Unwrap() error {
+	return e.Err
+}
+
+// Client is a minimal Redis protocol (RESP2) client with a small connection pool.
+// It works with Redis and compatible servers such as KeyDB, Dragonfly or Valkey.
+type Client struct {
+	addr     string
+	password string
+	timeout  time.Duration
+	pool     chan *conn
+}
+
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+}
+
+// NewClient creates a client for addr ("host:port"). Connections are opened lazily.
+func NewClient(addr, password string, poolSize int, timeout time.Duration) *Client {
+	if poolSize <= 0 {
+		poolSize = 8
+	}
+
+	return &Client{
+		addr:     addr,
+		password: password,
+		timeout:  timeout,
+		pool:     make(chan *conn, poolSize),
+	}
+}
+
+// Do sends a command and returns its reply. Replies are decoded as:
+// simple string and bulk string -> string, integer -> int64,
+// null -> nil, array -> []interface{}, error reply -> Error.
+func (cl *Client) Do(args ...string) (interface{}, error) {
+	replies, err := cl.Pipeline([][]string{args})
+	if err != nil {
+		return nil, err
+	}
+
+	if replyErr, ok := replies[0].(Error); ok {
+		return nil, replyErr
+	}
+	return replies[0], nil
+}
+
+// Pipeline sends several commands in one round trip and returns their replies
+// in order. Error replies are returned in place as Error values; failures
+// to talk to the server are returned as *ConnError.
+func (cl *Client) Pipeline(commands [][]string) ([]interface{}, error) {
+	c, err := cl.get()
+	if err != nil {
+		return nil, &ConnError{Err: err}
+	}
+
+	c.netConn.SetDeadline(time.Now().Add(cl.timeout))
+
+	replies, err := c.roundTrip(commands)
+	if err != nil {
+		// The connection state is unknown after an I/O error
+		c.netConn.Close()
+		return nil, &ConnError{Err: err}
+	}
+
+	cl.put(c)
+	return replies, nil
+}
+
+// Close closes idle connections
+func (cl *Client) Close() {
+	for {
+		select {
+		case c := <-cl.pool:
+			c.netConn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// get takes an idle connection or dials a new one
+func (cl *Client) get() (*conn, error) {
+	select {
+	case c := <-cl.pool:
+		return c, nil
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", cl.addr, cl.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conn{netConn: netConn, reader: bufio.NewReader(netConn)}
+
+	if cl.password != "" {
+		c.netConn.SetDeadline(time.Now().Add(cl.timeout))
+		replies, err := c.roundTrip([][]string{{"AUTH", cl.password}})
+		if err == nil {
+			if replyErr, ok := replies[0].(Error); ok {
+				err = replyErr
+			}
+		}
+		if err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("resp: auth failed: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// put returns a healthy connection to the pool, closing it if the pool is full
+func (cl *Client) put(c *conn) {
+	select {
+	case cl.pool <- c:
+	default:
+		c.netConn.Close()
+	}
+}
+
+// roundTrip writes all commands then reads one reply per command
+func (c *conn) roundTrip(commands [][]string) ([]interface{}, error) {
+	buf := make([]byte, 0, 128)
+	for _, args := range commands {
+		buf = AppendCommand(buf, args...)
+	}
+
+	if _, err := c.netConn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	for i := range commands {
+		reply, err := ReadReply(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+
+	return replies, nil
+}
+
+// AppendCommand encodes a command as a RESP array of bulk strings
+func AppendCommand(buf []byte, args ...string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+
+	return buf
+}
+
+// ReadReply decodes a single RESP value
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("resp: empty reply")
+	}
+
+	payload := line[1:]
+
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return Error(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = ReadReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("resp: unexpected reply type %q", line[0])
+	}
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("resp: malformed line")
+	}
+	return line[:len(line)-2], nil
+}
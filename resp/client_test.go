@@ -0,0 +1,114 @@
+package resp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daiwikmh/origami/resp"
+	"github.com/daiwikmh/origami/resp/resptest"
+)
+
+func newServer(t *testing.T) *resptest.Server {
+	t.Helper()
+
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientDo(t *testing.T) {
+	server := newServer(t)
+	client := resp.NewClient(server.Addr, "", 2, time.Second)
+	defer client.Close()
+
+	if reply, err := client.Do("SET", "greeting", "hello"); err != nil || reply != "OK" {
+		t.Fatalf("SET = %v, %v; want OK", reply, err)
+	}
+	if reply, err := client.Do("GET", "greeting"); err != nil || reply != "hello" {
+		t.Fatalf("GET = %v, %v; want hello", reply, err)
+	}
+	if reply, err := client.Do("GET", "missing"); err != nil || reply != nil {
+		t.Fatalf("GET missing = %v, %v; want nil", reply, err)
+	}
+	if reply, err := client.Do("INCR", "counter"); err != nil || reply != int64(1) {
+		t.Fatalf("INCR = %v, %v; want 1", reply, err)
+	}
+
+	if _, err := client.Do("NOSUCHCOMMAND"); err == nil {
+		t.Fatal("unknown command returned no error")
+	} else if _, ok := err.(resp.Error); !ok {
+		t.Fatalf("unknown command error is %T, want resp.Error", err)
+	}
+}
+
+func TestClientPipeline(t *testing.T) {
+	server := newServer(t)
+	client := resp.NewClient(server.Addr, "", 2, time.Second)
+	defer client.Close()
+
+	replies, err := client.Pipeline([][]string{
+		{"HINCRBY", "hash", "a", "2"},
+		{"NOSUCHCOMMAND"},
+		{"HINCRBY", "hash", "b", "5"},
+		{"HGETALL", "hash"},
+	})
+	if err != nil {
+		t.Fatalf("Pipeline: %v", err)
+	}
+	if len(replies) != 4 {
+		t.Fatalf("got %d replies, want 4", len(replies))
+	}
+
+	if replies[0] != int64(2) || replies[2] != int64(5) {
+		t.Errorf("HINCRBY replies = %v, %v; want 2, 5", replies[0], replies[2])
+	}
+	if _, ok := replies[1].(resp.Error); !ok {
+		t.Errorf("error reply is %T, want resp.Error in place", replies[1])
+	}
+	if items, ok := replies[3].([]interface{}); !ok || len(items) != 4 {
+		t.Errorf("HGETALL reply = %v, want 2 fields", replies[3])
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	server := newServer(t)
+	server.SetPassword("secret")
+
+	wrong := resp.NewClient(server.Addr, "guess", 1, time.Second)
+	defer wrong.Close()
+	if _, err := wrong.Do("PING"); err == nil {
+		t.Error("wrong password accepted")
+	}
+
+	right := resp.NewClient(server.Addr, "secret", 1, time.Second)
+	defer right.Close()
+	if reply, err := right.Do("PING"); err != nil || reply != "PONG" {
+		t.Errorf("PING = %v, %v; want PONG", reply, err)
+	}
+}
+
+func TestClientReconnects(t *testing.T) {
+	server := newServer(t)
+	client := resp.NewClient(server.Addr, "", 1, time.Second)
+	defer client.Close()
+
+	if _, err := client.Do("PING"); err != nil {
+		t.Fatalf("PING: %v", err)
+	}
+
+	// The pooled connection dies with the server
+	server.Close()
+	if _, err := client.Do("PING"); err == nil {
+		t.Fatal("PING succeeded while the server was down")
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("restarting server: %v", err)
+	}
+	if reply, err := client.Do("PING"); err != nil || reply != "PONG" {
+		t.Fatalf("PING after restart = %v, %v; want PONG", reply, err)
+	}
+}
@@ -0,0 +1,359 @@
+// Package resptest provides an in-process Redis protocol server for tests
+package resptest
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/resp"
+)
+
+// status is a simple string reply
+type status string
+
+// Server is an in-process stand-in for a Redis-protocol server. It supports
+// the string, counter and hash commands origami uses, with key expiry. Data
+// survives Close, so a server can be stopped and restarted to simulate an
+// outage.
+type Server struct {
+	Addr string
+
+	password string // Required by AUTH when set
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	strings  map[string]string
+	hashes   map[string]map[string]string
+	expires  map[string]time.Time
+	mu       sync.Mutex
+}
+
+// NewServer starts a server on a free local port
+func NewServer() (*Server, error) {
+	s := &Server{
+		conns:   make(map[net.Conn]struct{}),
+		strings: make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		expires: make(map[string]time.Time),
+	}
+
+	if err := s.listen("127.0.0.1:0"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close stops accepting connections and drops open ones
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.conns = make(map[net.Conn]struct{})
+}
+
+// SetPassword makes new connections authenticate with AUTH password
+func (s *Server) SetPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.password = password
+}
+
+// Restart listens again on the address of a closed server
+func (s *Server) Restart() error {
+	return s.listen(s.Addr)
+}
+
+// listen accepts connections on addr in the background
+func (s *Server) listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.Addr = listener.Addr().String()
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.conns[c] = struct{}{}
+			s.mu.Unlock()
+
+			go s.serve(c)
+		}
+	}()
+
+	return nil
+}
+
+// serve answers the commands of one connection
+func (s *Server) serve(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	s.mu.Lock()
+	password := s.password
+	s.mu.Unlock()
+
+	reader := bufio.NewReader(c)
+	authed := password == ""
+
+	for {
+		request, err := resp.ReadReply(reader)
+		if err != nil {
+			return
+		}
+
+		items, _ := request.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+
+		var reply interface{}
+		switch {
+		case len(args) == 0:
+			reply = resp.Error("ERR empty command")
+		case strings.ToUpper(args[0]) == "AUTH":
+			authed = len(args) == 2 && args[1] == password
+			reply = status("OK")
+			if !authed {
+				reply = resp.Error("WRONGPASS invalid password")
+			}
+		case !authed:
+			reply = resp.Error("NOAUTH Authentication required.")
+		default:
+			reply = s.exec(args)
+		}
+
+		if _, err := c.Write(appendReply(nil, reply)); err != nil {
+			return
+		}
+	}
+}
+
+// exec runs one command
+func (s *Server) exec(args []string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	command := strings.ToUpper(args[0])
+	if len(args) > 1 {
+		s.expire(args[1])
+	}
+
+	switch {
+	case command == "PING":
+		return status("PONG")
+
+	case command == "GET" && len(args) == 2:
+		if value, exists := s.strings[args[1]]; exists {
+			return value
+		}
+		return nil
+
+	case command == "SET" && len(args) >= 3:
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				if s.exists(args[1]) {
+					return nil
+				}
+			case "PX", "EX":
+				if i+1 >= len(args) {
+					return resp.Error("ERR syntax error")
+				}
+				n, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil || n <= 0 {
+					return resp.Error("ERR invalid expire time")
+				}
+				ttl = time.Duration(n) * time.Millisecond
+				if strings.ToUpper(args[i]) == "EX" {
+					ttl = time.Duration(n) * time.Second
+				}
+				i++
+			default:
+				return resp.Error("ERR syntax error")
+			}
+		}
+
+		s.delete(args[1])
+		s.strings[args[1]] = args[2]
+		if ttl > 0 {
+			s.expires[args[1]] = time.Now().Add(ttl)
+		}
+		return status("OK")
+
+	case command == "DEL" && len(args) >= 2:
+		var deleted int64
+		for _, key := range args[1:] {
+			s.expire(key)
+			if s.exists(key) {
+				s.delete(key)
+				deleted++
+			}
+		}
+		return deleted
+
+	case command == "INCR" && len(args) == 2:
+		if _, isHash := s.hashes[args[1]]; isHash {
+			return resp.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+		}
+		n, err := strconv.ParseInt(orZero(s.strings[args[1]]), 10, 64)
+		if err != nil {
+			return resp.Error("ERR value is not an integer or out of range")
+		}
+		n++
+		s.strings[args[1]] = strconv.FormatInt(n, 10)
+		return n
+
+	case command == "PEXPIRE" && len(args) == 3:
+		ms, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return resp.Error("ERR value is not an integer or out of range")
+		}
+		if !s.exists(args[1]) {
+			return int64(0)
+		}
+		s.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return int64(1)
+
+	case command == "HINCRBY" && len(args) == 4:
+		if _, isString := s.strings[args[1]]; isString {
+			return resp.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+		}
+		delta, err := strconv.ParseInt(args[3], 10, 64)
+		if err != nil {
+			return resp.Error("ERR value is not an integer or out of range")
+		}
+		hash, exists := s.hashes[args[1]]
+		if !exists {
+			hash = make(map[string]string)
+			s.hashes[args[1]] = hash
+		}
+		n, err := strconv.ParseInt(orZero(hash[args[2]]), 10, 64)
+		if err != nil {
+			return resp.Error("ERR hash value is not an integer")
+		}
+		n += delta
+		hash[args[2]] = strconv.FormatInt(n, 10)
+		return n
+
+	case command == "HSET" && len(args) >= 4 && len(args)%2 == 0:
+		if _, isString := s.strings[args[1]]; isString {
+			return resp.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+		}
+		hash, exists := s.hashes[args[1]]
+		if !exists {
+			hash = make(map[string]string)
+			s.hashes[args[1]] = hash
+		}
+		var added int64
+		for i := 2; i < len(args); i += 2 {
+			if _, exists := hash[args[i]]; !exists {
+				added++
+			}
+			hash[args[i]] = args[i+1]
+		}
+		return added
+
+	case command == "HGET" && len(args) == 3:
+		if value, exists := s.hashes[args[1]][args[2]]; exists {
+			return value
+		}
+		return nil
+
+	case command == "HGETALL" && len(args) == 2:
+		items := make([]interface{}, 0, 2*len(s.hashes[args[1]]))
+		for field, value := range s.hashes[args[1]] {
+			items = append(items, field, value)
+		}
+		return items
+	}
+
+	return resp.Error("ERR unknown command or wrong number of arguments for '" + args[0] + "'")
+}
+
+// exists reports whether a key holds a value (caller must hold the lock)
+func (s *Server) exists(key string) bool {
+	_, isString := s.strings[key]
+	_, isHash := s.hashes[key]
+	return isString || isHash
+}
+
+// delete removes a key (caller must hold the lock)
+func (s *Server) delete(key string) {
+	delete(s.strings, key)
+	delete(s.hashes, key)
+	delete(s.expires, key)
+}
+
+// expire removes a key past its expiry (caller must hold the lock)
+func (s *Server) expire(key string) {
+	if expiry, exists := s.expires[key]; exists && !time.Now().Before(expiry) {
+		s.delete(key)
+	}
+}
+
+// orZero treats a missing counter as zero
+func orZero(value string) string {
+	if value == "" {
+		return "0"
+	}
+	return value
+}
+
+// appendReply encodes a reply value
+func appendReply(buf []byte, reply interface{}) []byte {
+	switch v := reply.(type) {
+	case nil:
+		return append(buf, "$-1\r\n"...)
+	case status:
+		return append(append(append(buf, '+'), v...), '\r', '\n')
+	case resp.Error:
+		return append(append(append(buf, '-'), v...), '\r', '\n')
+	case int64:
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, v, 10)
+		return append(buf, '\r', '\n')
+	case string:
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(v)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, v...)
+		return append(buf, '\r', '\n')
+	case []interface{}:
+		buf = append(buf, '*')
+		buf = strconv.AppendInt(buf, int64(len(v)), 10)
+		buf = append(buf, '\r', '\n')
+		for _, item := range v {
+			buf = appendReply(buf, item)
+		}
+		return buf
+	}
+
+	return append(buf, "-ERR unsupported reply\r\n"...)
+}
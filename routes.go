@@ -1,45 +1,90 @@
 package main
 
 import (
+	"log"
+
+	"github.com/daiwikmh/origami/abuse"
 	"github.com/daiwikmh/origami/auth"
+	"github.com/daiwikmh/origami/billing"
+	"github.com/daiwikmh/origami/cache"
+	"github.com/daiwikmh/origami/config"
+	"github.com/daiwikmh/origami/counters"
 	"github.com/daiwikmh/origami/handlers"
 	"github.com/daiwikmh/origami/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(keyStore *auth.KeyStore) *gin.Engine {
+func SetupRouter(cfg *config.Config, marketStore cache.MarketStore, counterStore counters.Store, keyStore *auth.KeyStore, tokens *auth.TokenIssuer, signatures *auth.SignatureVerifier, detector *abuse.Detector, meter *billing.Service) *gin.Engine {
 	r := gin.Default()
 
-	// Public endpoints (no auth required)
-	r.GET("/info", handlers.GetSystemInfo)
-	r.GET("/", handlers.AdminDashboard)
-	r.GET("/test", handlers.TestAPIEndpoint)
-	r.GET("/docs", handlers.ServeDocs)
+	// Only trust X-Forwarded-For from configured proxies, otherwise key IP
+	// allowlists and per-IP limits can be bypassed by spoofing the header.
+	// Without any, the client IP is the connection's remote address.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid ORIGAMI_TRUSTED_PROXIES: %v", err)
+	}
+
+	r.Use(middleware.SecurityHeaders(cfg.HSTS))
+
+	// Answer CORS preflights before routing; they carry no API key
+	r.Use(middleware.CORSPreflight())
+
+	// Public endpoints (no auth required), rate limited per client IP
+	public := r.Group("/")
+	public.Use(middleware.PublicRateLimiter(counterStore, cfg.PublicRateLimit))
+	{
+		public.GET("/info", handlers.GetSystemInfo)
+		public.GET("/docs", middleware.PageSecurityPolicy(), handlers.ServeDocs)
+
+		// The dashboard and API tester drive the admin API, disable them in production
+		if cfg.EnableDashboard {
+			public.GET("/", middleware.PageSecurityPolicy(), handlers.AdminDashboard)
+			public.GET("/test", middleware.PageSecurityPolicy(), handlers.TestAPIEndpoint)
+		}
+	}
 
 	// Admin endpoints (no auth for demo purposes - add auth in production)
 	admin := r.Group("/admin")
+	admin.Use(middleware.PublicRateLimiter(counterStore, cfg.PublicRateLimit))
 	{
 		admin.POST("/keys/generate", handlers.GenerateAPIKey)
 		admin.GET("/keys", handlers.ListAPIKeys)
 		admin.POST("/keys/revoke", handlers.RevokeAPIKey)
+		admin.POST("/keys/restrictions", handlers.SetKeyRestrictions)
+		admin.POST("/keys/enforcement/clear", handlers.ClearKeyEnforcement)
+		admin.GET("/audit", handlers.GetAuditLog)
+		admin.POST("/keys/billing", handlers.SetKeyBilling)
+		admin.GET("/billing/plans", handlers.GetBillingPlans)
+		admin.GET("/billing/:period", handlers.GetBillingStatements)
 		admin.GET("/usage", handlers.GetUsageStats)
+		admin.GET("/metrics", handlers.GetMetrics)
 	}
 
 	// Protected API routes under /origami namespace
 	origami := r.Group("/origami")
-	origami.Use(middleware.APIKeyAuth(keyStore))
+	origami.Use(middleware.APIKeyAuth(keyStore, tokens, signatures))
+	origami.Use(middleware.AbuseMonitor(keyStore, detector))
+	origami.Use(middleware.KeyRestrictions(keyStore))
+	origami.Use(middleware.CORS())
 	origami.Use(middleware.RateLimiter(keyStore))
-	origami.Use(middleware.UsageTracker(keyStore))
+	origami.Use(middleware.UsageTracker(keyStore, meter))
+	origami.Use(middleware.MarketReads(marketStore))
 	{
 		// Market endpoints
 		origami.GET("/markets", handlers.GetMarkets)
 		origami.GET("/markets/summary", handlers.GetMarketSummary)
 		origami.GET("/markets/:id/liquidity", handlers.GetLiquidity)
+		origami.GET("/markets/:id/trades", handlers.GetTrades)
+		origami.GET("/markets/:id/flow", handlers.GetOrderFlow)
+		origami.GET("/markets/:id/impact", handlers.GetPriceImpact)
 
 		// Analytics endpoints
 		origami.GET("/markets/:id/analytics", handlers.GetMarketAnalytics)
 		origami.GET("/markets/:id/volatility", handlers.GetVolatility)
 		origami.GET("/markets/:id/depth", handlers.GetOrderbookDepth)
+		origami.GET("/markets/:id/candles", handlers.GetCandles)
+		origami.GET("/markets/:id/indicators", handlers.GetIndicators)
+		origami.GET("/markets/:id/changes", handlers.GetPriceChanges)
 
 		// Signal endpoints
 		origami.GET("/signals/trending", handlers.GetTrending)
@@ -47,6 +92,9 @@ func SetupRouter(keyStore *auth.KeyStore) *gin.Engine {
 		origami.GET("/signals/volatile", handlers.GetVolatilityRanking)
 		origami.GET("/signals/volume", handlers.GetVolumeLeaders)
 
+		// Access token exchange
+		origami.POST("/token", handlers.IssueAccessToken)
+
 		// User endpoints
 		origami.GET("/me", handlers.GetKeyUsage)
 		origami.GET("/me/limits", handlers.GetRateLimitInfo)
@@ -1,8 +1,6 @@
 package services
 
 import (
-	"math"
-	"sort"
 	"time"
 
 	"github.com/daiwikmh/origami/cache"
@@ -14,63 +12,10 @@ func CalculateSpread(bid, ask float64) float64 {
 	return ask - bid
 }
 
-func LiquidityScore(volume, spread float64) float64 {
-	return volume / (spread + 1)
-}
-
-func Volatility(prices []float64) float64 {
-	if len(prices) == 0 {
-		return 0
-	}
-
-	var mean float64
-	for _, p := range prices {
-		mean += p
-	}
-	mean /= float64(len(prices))
-
-	var variance float64
-	for _, p := range prices {
-		variance += math.Pow(p-mean, 2)
-	}
-
-	return math.Sqrt(variance / float64(len(prices)))
-}
-
 func TrendingScore(volume, volatility float64) float64 {
 	return volume * volatility
 }
 
-// CalculateRealLiquidity computes liquidity from orderbook depth
-func CalculateRealLiquidity(orderbookData interface{}) float64 {
-	obMap, ok := orderbookData.(map[string]interface{})
-	if !ok {
-		return 0
-	}
-
-	orderbook, ok := obMap["orderbook"].(map[string]interface{})
-	if !ok {
-		return 0
-	}
-
-	buys, _ := utils.ExtractOrderbookLevels(orderbook, "buys")
-	sells, _ := utils.ExtractOrderbookLevels(orderbook, "sells")
-
-	// Calculate total liquidity in top 10 levels
-	var bidLiquidity, askLiquidity float64
-
-	limit := 10
-	for i := 0; i < limit && i < len(buys); i++ {
-		bidLiquidity += buys[i].Price * buys[i].Quantity
-	}
-
-	for i := 0; i < limit && i < len(sells); i++ {
-		askLiquidity += sells[i].Price * sells[i].Quantity
-	}
-
-	return bidLiquidity + askLiquidity
-}
-
 // CalculateOrderbookDepth computes multi-level depth metrics
 func CalculateOrderbookDepth(orderbookData interface{}) *models.OrderbookDepth {
 	obMap, ok := orderbookData.(map[string]interface{})
@@ -122,29 +67,50 @@ func CalculateOrderbookDepth(orderbookData interface{}) *models.OrderbookDepth {
 		depth.SpreadBps = (depth.Spread / depth.MidPrice) * 10000
 	}
 
+	depth.Bands = DepthBands(buys, sells, depth.MidPrice, DefaultDepthBands)
+
 	return depth
 }
 
-// CalculateMarketVolatility calculates volatility using cached price history
-func CalculateMarketVolatility(marketID string, dataCache *cache.DataCache) float64 {
+// CalculateMarketVolatility calculates annualized realized volatility using cached price history
+func CalculateMarketVolatility(marketID string, dataCache cache.MarketStore) float64 {
 	history, exists := dataCache.GetPriceHistory(marketID)
 	if !exists || len(history.Prices) < 2 {
 		return 0
 	}
 
-	return Volatility(history.Prices)
+	return RealizedVolatility(history.Prices, history.Times)
+}
+
+// ComputeMarketAnalytics calculates comprehensive analytics for a market,
+// reading its full price history and trades from the store
+func ComputeMarketAnalytics(marketID string, dataCache cache.MarketStore) *models.MarketAnalytics {
+	started := time.Now()
+	analytics := computeAnalytics(NewAnalyticsState(marketID, dataCache), dataCache)
+	recordCompute(marketID, time.Since(started), analytics != nil)
+	return analytics
 }
 
-// ComputeMarketAnalytics calculates comprehensive analytics for a market
-func ComputeMarketAnalytics(marketID string, dataCache *cache.DataCache) *models.MarketAnalytics {
+// Compute calculates analytics for the state's market from its running
+// inputs and the current orderbook
+func (s *AnalyticsState) Compute(dataCache cache.MarketStore) *models.MarketAnalytics {
+	started := time.Now()
+	analytics := computeAnalytics(s, dataCache)
+	recordCompute(s.MarketID, time.Since(started), analytics != nil)
+	return analytics
+}
+
+// computeAnalytics calculates analytics from a market's state and orderbook
+func computeAnalytics(state *AnalyticsState, dataCache cache.MarketStore) *models.MarketAnalytics {
+	marketID := state.MarketID
+
 	// Get orderbook
-	orderbookData, found := dataCache.GetOrderbook(marketID)
+	orderbookEntry, found := dataCache.GetOrderbookEntry(marketID)
 	if !found {
 		return nil
 	}
-
-	// Get price history
-	priceHistory, _ := dataCache.GetPriceHistory(marketID)
+	orderbookData := orderbookEntry.Data
+	orderbookAsOf := orderbookEntry.StoredAt
 
 	// Extract market info from orderbook
 	obMap, ok := orderbookData.(map[string]interface{})
@@ -171,35 +137,37 @@ func ComputeMarketAnalytics(marketID string, dataCache *cache.DataCache) *models
 	depth := CalculateOrderbookDepth(orderbookData)
 
 	// Calculate volatility
-	volatility := 0.0
-	if priceHistory != nil && len(priceHistory.Prices) > 0 {
-		volatility = Volatility(priceHistory.Prices)
-	}
+	volatility := state.volatility()
 
-	// Calculate 24h price change
+	// Calculate price changes against timestamped history; the 24h change
+	// stays zero until a day of history exists
+	priceChanges := PriceChanges(marketID, currentPrice, time.Now())
 	priceChange24h := 0.0
 	priceChange24hPct := 0.0
-	if priceHistory != nil && len(priceHistory.Prices) > 0 {
-		oldPrice := priceHistory.Prices[0]
-		priceChange24h = currentPrice - oldPrice
-		priceChange24hPct = utils.PercentageChange(oldPrice, currentPrice)
+	for _, change := range priceChanges {
+		if change.Horizon == "24h" && !change.InsufficientHistory {
+			priceChange24h = *change.Change
+			priceChange24hPct = *change.ChangePct
+		}
 	}
 
-	// Calculate volume (simplified - from trades if available)
-	volume24h := 0.0
-	trades, found := dataCache.GetTrades(marketID)
-	if found {
-		for _, trade := range trades {
-			volume24h += trade.Price * trade.Quantity
-		}
+	// Calculate volume (simplified - from cached trades)
+	volume24h := state.volume.Sum()
+	tradesAsOf := state.lastTradeAt
+
+	// Prefer the time-series store, which covers a full day
+	if volume, ok := historyVolume24h(marketID); ok && volume > 0 {
+		volume24h = volume
 	}
 
-	// Calculate liquidity score
-	spread := depth.Spread
-	liquidityScore := LiquidityScore(volume24h, spread)
+	// Summarize order flow of the last hour
+	var orderFlow *models.OrderFlow
+	if tradesAsOf != nil {
+		orderFlow = state.flow.Flow(time.Now())
+	}
 
-	// Calculate trending score
-	trendingScore := CalculateTrendingScoreEnhanced(volume24h, volatility, math.Abs(priceChange24hPct))
+	// Measure liquidity, scored against other markets when served
+	liquidity := measureLiquidity(marketID, depth, volume24h)
 
 	return &models.MarketAnalytics{
 		MarketID:         marketID,
@@ -209,51 +177,16 @@ func ComputeMarketAnalytics(marketID string, dataCache *cache.DataCache) *models
 		Volume24h:        volume24h,
 		PriceChange24h:   priceChange24h,
 		PriceChange24hPct: priceChange24hPct,
+		PriceChanges:     priceChanges,
 		Volatility:       volatility,
-		LiquidityScore:   liquidityScore,
-		TrendingScore:    trendingScore,
+		Liquidity:        liquidity,
 		OrderbookDepth:   depth,
+		OrderFlow:        orderFlow,
 		Timestamp:        time.Now(),
+		Freshness: models.Freshness{
+			DataAsOf:      orderbookAsOf,
+			OrderbookAsOf: &orderbookAsOf,
+			TradesAsOf:    tradesAsOf,
+		},
 	}
 }
-
-// CalculateTrendingScoreEnhanced uses weighted formula
-// Volume: 40%, Volatility: 30%, Price Change: 30%
-func CalculateTrendingScoreEnhanced(volume, volatility, priceChange float64) float64 {
-	// Normalize components (simple scaling)
-	volumeScore := math.Log10(volume + 1) * 0.4
-	volatilityScore := volatility * 0.3
-	priceChangeScore := priceChange * 0.3
-
-	return volumeScore + volatilityScore + priceChangeScore
-}
-
-// GetTopTrendingMarkets returns top N markets by trending score
-func GetTopTrendingMarkets(limit int, dataCache *cache.DataCache) []*models.TrendingMarket {
-	allAnalytics := dataCache.GetAllAnalytics()
-
-	// Convert to trending markets
-	trendingMarkets := make([]*models.TrendingMarket, 0, len(allAnalytics))
-	for _, analytics := range allAnalytics {
-		trendingMarkets = append(trendingMarkets, &models.TrendingMarket{
-			MarketID:    analytics.MarketID,
-			Symbol:      analytics.BaseDenom + "/" + analytics.QuoteDenom,
-			Score:       analytics.TrendingScore,
-			Volume24h:   analytics.Volume24h,
-			Volatility:  analytics.Volatility,
-			PriceChange: analytics.PriceChange24hPct,
-		})
-	}
-
-	// Sort by score descending
-	sort.Slice(trendingMarkets, func(i, j int) bool {
-		return trendingMarkets[i].Score > trendingMarkets[j].Score
-	})
-
-	// Return top N
-	if len(trendingMarkets) > limit {
-		trendingMarkets = trendingMarkets[:limit]
-	}
-
-	return trendingMarkets
-}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+	"github.com/daiwikmh/origami/tsdb"
+)
+
+// CandleIntervals maps supported intervals to bar widths
+var CandleIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// candleRollups is the time-series rollup each interval is built from
+var candleRollups = map[string]tsdb.Resolution{
+	"1m":  tsdb.Minute,
+	"5m":  tsdb.FiveMinutes,
+	"15m": tsdb.FiveMinutes,
+	"1h":  tsdb.Hour,
+	"4h":  tsdb.Hour,
+	"1d":  tsdb.Day,
+}
+
+// ErrUnknownInterval is returned for intervals not in CandleIntervals
+var ErrUnknownInterval = errors.New("unknown interval")
+
+// GetCandles builds OHLCV bars for [from, to]. Bars are aligned to the interval
+// in UTC. Intervals with trades are built from the cached trade stream; the
+// others fall back to sampled mid prices, and intervals without any data carry
+// the previous close forward. Bars before the first data point are omitted.
+// Prices are in quote tokens per base token, Volume in base tokens and
+// QuoteVolume in quote tokens.
+func GetCandles(marketID, interval string, from, to time.Time) ([]*models.Candle, error) {
+	width, exists := CandleIntervals[interval]
+	if !exists {
+		return nil, ErrUnknownInterval
+	}
+
+	meta, err := GetMarketMeta(marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	from = from.UTC().Truncate(width)
+	now := time.Now()
+	if to.After(now) {
+		to = now
+	}
+
+	tradeBars := make(map[int64]*models.Candle)
+	if dataCache != nil {
+		if trades, found := dataCache.GetTrades(marketID); found {
+			for _, trade := range trades {
+				if trade.Timestamp.Before(from) || trade.Timestamp.After(to) {
+					continue
+				}
+
+				start := trade.Timestamp.UTC().Truncate(width)
+				bar, ok := tradeBars[start.UnixNano()]
+				if !ok {
+					bar = &models.Candle{
+						Time:   start,
+						Open:   trade.Price,
+						High:   trade.Price,
+						Low:    trade.Price,
+						Source: models.CandleSourceTrades,
+					}
+					tradeBars[start.UnixNano()] = bar
+				}
+
+				// Trades are cached oldest first
+				bar.High = max(bar.High, trade.Price)
+				bar.Low = min(bar.Low, trade.Price)
+				bar.Close = trade.Price
+				bar.Volume += trade.Quantity
+				bar.QuoteVolume += trade.Price * trade.Quantity
+				bar.Trades++
+			}
+		}
+	}
+
+	midBars := make(map[int64]*models.Candle)
+	if history != nil {
+		aggregates, err := history.Aggregates(marketID, candleRollups[interval], from, to)
+		if err != nil && !errors.Is(err, tsdb.ErrInvalidMarketID) {
+			return nil, err
+		}
+
+		for _, a := range aggregates {
+			start := a.Start.Truncate(width)
+			bar, ok := midBars[start.UnixNano()]
+			if !ok {
+				bar = &models.Candle{Time: start, Source: models.CandleSourceMid}
+				midBars[start.UnixNano()] = bar
+			}
+
+			// Volume-only buckets carry no price
+			bar.QuoteVolume += a.Volume
+			if a.Samples == 0 {
+				continue
+			}
+			if bar.Open == 0 {
+				bar.Open, bar.High, bar.Low = a.Open, a.High, a.Low
+			}
+			bar.High = max(bar.High, a.High)
+			bar.Low = min(bar.Low, a.Low)
+			bar.Close = a.Close
+		}
+	}
+
+	candles := make([]*models.Candle, 0)
+	var previous *models.Candle
+
+	for start := from; !start.After(to); start = start.Add(width) {
+		key := start.UnixNano()
+
+		bar := tradeBars[key]
+		if bar == nil {
+			if mid := midBars[key]; mid != nil && mid.Open > 0 {
+				bar = mid
+			}
+		}
+		if bar != nil {
+			normalizeCandle(meta, bar)
+		} else {
+			if previous == nil {
+				continue
+			}
+			bar = &models.Candle{
+				Time:   start,
+				Open:   previous.Close,
+				High:   previous.Close,
+				Low:    previous.Close,
+				Close:  previous.Close,
+				Source: models.CandleSourceFilled,
+			}
+		}
+
+		bar.Partial = now.Before(start.Add(width))
+		candles = append(candles, bar)
+		previous = bar
+	}
+
+	return candles, nil
+}
+
+// normalizeCandle converts a bar built from chain prices and quantities to tokens
+func normalizeCandle(meta *MarketMeta, bar *models.Candle) {
+	bar.Open = meta.NormalizePrice(bar.Open)
+	bar.High = meta.NormalizePrice(bar.High)
+	bar.Low = meta.NormalizePrice(bar.Low)
+	bar.Close = meta.NormalizePrice(bar.Close)
+	bar.Volume = meta.NormalizeQuantity(bar.Volume)
+	bar.QuoteVolume = meta.NormalizeNotional(bar.QuoteVolume)
+}
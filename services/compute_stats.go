@@ -0,0 +1,58 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// ComputeStats describes the analytics computations of one market
+type ComputeStats struct {
+	Computations int64     `json:"computations"`
+	Failures     int64     `json:"failures"` // Computations without an orderbook to work from
+	LastMs       float64   `json:"last_ms"`  // Duration of the last computation
+	AvgMs        float64   `json:"avg_ms"`   // Exponentially weighted average duration
+	MaxMs        float64   `json:"max_ms"`
+	LastAt       time.Time `json:"last_at"`
+}
+
+var (
+	computeStats   = make(map[string]*ComputeStats)
+	computeStatsMu sync.Mutex
+)
+
+// recordCompute adds an analytics computation to its market's stats
+func recordCompute(marketID string, took time.Duration, ok bool) {
+	ms := float64(took.Microseconds()) / 1000
+
+	computeStatsMu.Lock()
+	defer computeStatsMu.Unlock()
+
+	stats, exists := computeStats[marketID]
+	if !exists {
+		stats = &ComputeStats{AvgMs: ms}
+		computeStats[marketID] = stats
+	}
+
+	stats.Computations++
+	if !ok {
+		stats.Failures++
+	}
+	stats.LastMs = ms
+	stats.AvgMs = 0.9*stats.AvgMs + 0.1*ms
+	if ms > stats.MaxMs {
+		stats.MaxMs = ms
+	}
+	stats.LastAt = time.Now()
+}
+
+// GetComputeStats returns analytics computation stats per market
+func GetComputeStats() map[string]ComputeStats {
+	computeStatsMu.Lock()
+	defer computeStatsMu.Unlock()
+
+	result := make(map[string]ComputeStats, len(computeStats))
+	for marketID, stats := range computeStats {
+		result[marketID] = *stats
+	}
+	return result
+}
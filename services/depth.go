@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+	"github.com/daiwikmh/origami/utils"
+)
+
+const (
+	// maxDepthBands bounds the bands of one request
+	maxDepthBands = 10
+
+	// maxDepthBandPercent is the widest band around mid
+	maxDepthBandPercent = 50.0
+)
+
+// DefaultDepthBands are the percentage bands around mid included in analytics
+var DefaultDepthBands = []float64{0.5, 1, 2, 5}
+
+var (
+	// ErrInvalidBands is returned for band lists ParseDepthBands rejects
+	ErrInvalidBands = errors.New("invalid depth bands")
+	// ErrOrderbookUnavailable is returned when an orderbook lacks bids or asks
+	ErrOrderbookUnavailable = errors.New("orderbook unavailable")
+)
+
+// ParseDepthBands parses a comma-separated list of percentages such as
+// "0.25,1,10". Bands must be above 0 and at most 50, and are returned sorted.
+func ParseDepthBands(value string) ([]float64, error) {
+	bands := make([]float64, 0)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		percent, err := strconv.ParseFloat(part, 64)
+		if err != nil || !(percent > 0) || percent > maxDepthBandPercent {
+			return nil, ErrInvalidBands
+		}
+		bands = append(bands, percent)
+	}
+
+	if len(bands) == 0 || len(bands) > maxDepthBands {
+		return nil, ErrInvalidBands
+	}
+
+	sort.Float64s(bands)
+	return bands, nil
+}
+
+// DepthBands sums the resting liquidity within each percentage of mid. Levels
+// may be in any order.
+func DepthBands(buys, sells []utils.OrderLevel, mid float64, bands []float64) []models.DepthBand {
+	result := make([]models.DepthBand, len(bands))
+
+	for i, percent := range bands {
+		band := models.DepthBand{Percent: percent}
+		low := mid * (1 - percent/100)
+		high := mid * (1 + percent/100)
+
+		for _, level := range buys {
+			if level.Price >= low && level.Price <= mid {
+				band.BidQuantity += level.Quantity
+				band.BidNotional += level.Price * level.Quantity
+			}
+		}
+		for _, level := range sells {
+			if level.Price <= high && level.Price >= mid {
+				band.AskQuantity += level.Quantity
+				band.AskNotional += level.Price * level.Quantity
+			}
+		}
+
+		result[i] = band
+	}
+
+	return result
+}
+
+// DepthLadder accumulates each side outward from its best price. Levels must
+// be best first, as orderbookLevels returns them.
+func DepthLadder(buys, sells []utils.OrderLevel) *models.DepthLadder {
+	return &models.DepthLadder{
+		Bids: cumulative(buys),
+		Asks: cumulative(sells),
+	}
+}
+
+// cumulative returns the running totals of one side of the book
+func cumulative(levels []utils.OrderLevel) []models.DepthStep {
+	steps := make([]models.DepthStep, 0, len(levels))
+
+	var quantity, notional float64
+	for _, level := range levels {
+		quantity += level.Quantity
+		notional += level.Price * level.Quantity
+
+		steps = append(steps, models.DepthStep{
+			Price:              level.Price,
+			Quantity:           level.Quantity,
+			CumulativeQuantity: quantity,
+			CumulativeNotional: notional,
+		})
+	}
+
+	return steps
+}
+
+// GetDepthChart computes depth of a market's current orderbook with the given
+// bands, and its cumulative ladder when requested
+func GetDepthChart(marketID string, bands []float64, ladder bool) (*models.DepthChart, error) {
+	orderbook, err := GetOrderbook(marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := CalculateOrderbookDepth(orderbook)
+	if depth == nil {
+		return nil, ErrOrderbookUnavailable
+	}
+
+	buys, sells := orderbookLevels(orderbook)
+	depth.Bands = DepthBands(buys, sells, depth.MidPrice, bands)
+
+	chart := &models.DepthChart{OrderbookDepth: depth}
+	if ladder {
+		chart.Ladder = DepthLadder(buys, sells)
+	}
+
+	asOf := time.Now()
+	if dataCache != nil {
+		if entry, found := dataCache.GetOrderbookEntry(marketID); found {
+			asOf = entry.StoredAt
+		}
+	}
+
+	age := time.Since(asOf)
+	chart.Freshness = models.Freshness{
+		DataAsOf:      asOf,
+		AgeMs:         age.Milliseconds(),
+		Stale:         age > analyticsFreshFor,
+		OrderbookAsOf: &asOf,
+	}
+
+	return chart, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/daiwikmh/origami/coalesce"
+)
+
+const (
+	// unknownMarketTTL is how long a market ID missing from the market list is
+	// answered with ErrMarketNotFound without looking it up again
+	unknownMarketTTL = time.Minute
+
+	// maxUnknownMarkets bounds the negative cache against floods of random IDs
+	maxUnknownMarkets = 10000
+)
+
+var (
+	// On-demand upstream fetches, coalesced per key
+	marketsFetches   = coalesce.NewGroup()
+	orderbookFetches = coalesce.NewGroup()
+
+	unknownMarkets = &negativeCache{entries: make(map[string]time.Time)}
+)
+
+// FetchStats reports on-demand upstream fetches by kind and the unknown market cache
+type FetchStats struct {
+	Markets        coalesce.Stats `json:"markets"`
+	Orderbook      coalesce.Stats `json:"orderbook"`
+	UnknownMarkets int            `json:"unknown_markets"` // Market IDs currently cached as unknown
+	UnknownHits    int64          `json:"unknown_hits"`    // Requests answered from that cache
+}
+
+// GetFetchStats returns counters of on-demand upstream fetches
+func GetFetchStats() FetchStats {
+	return FetchStats{
+		Markets:        marketsFetches.Stats(),
+		Orderbook:      orderbookFetches.Stats(),
+		UnknownMarkets: unknownMarkets.size(),
+		UnknownHits:    unknownMarkets.hits.Load(),
+	}
+}
+
+// negativeCache remembers market IDs that are not listed upstream
+type negativeCache struct {
+	entries map[string]time.Time // Market ID to expiry
+	mu      sync.Mutex
+	hits    atomic.Int64
+}
+
+// has reports whether a market is cached as unknown
+func (nc *negativeCache) has(marketID string) bool {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	expiresAt, exists := nc.entries[marketID]
+	if !exists {
+		return false
+	}
+	if time.Now().After(expiresAt) {
+		delete(nc.entries, marketID)
+		return false
+	}
+
+	nc.hits.Add(1)
+	return true
+}
+
+// add caches a market as unknown, dropping expired entries. When the cache
+// is full the market is not cached and will be looked up again.
+func (nc *negativeCache) add(marketID string) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	now := time.Now()
+	if len(nc.entries) >= maxUnknownMarkets {
+		for id, expiresAt := range nc.entries {
+			if now.After(expiresAt) {
+				delete(nc.entries, id)
+			}
+		}
+		if len(nc.entries) >= maxUnknownMarkets {
+			return
+		}
+	}
+
+	nc.entries[marketID] = now.Add(unknownMarketTTL)
+}
+
+// size returns the number of cached entries
+func (nc *negativeCache) size() int {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	return len(nc.entries)
+}
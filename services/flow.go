@@ -0,0 +1,228 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/daiwikmh/origami/cache"
+	"github.com/daiwikmh/origami/models"
+)
+
+// analyticsFlowWindow is the order flow window included in market analytics
+const analyticsFlowWindow = "1h"
+
+// FlowWindows maps supported order flow windows to their length
+var FlowWindows = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"24h": 24 * time.Hour,
+}
+
+// GetOrderFlow returns the order flow of a market's cached trades over a
+// window, normalized to token units
+func GetOrderFlow(marketID, window string) (*models.OrderFlow, error) {
+	if _, exists := FlowWindows[window]; !exists {
+		return nil, ErrUnknownWindow
+	}
+
+	meta, err := GetMarketMeta(marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	var trades []*models.Trade
+	if dataCache != nil {
+		trades, _ = dataCache.GetTrades(marketID)
+	}
+
+	return OrderFlow(trades, window, time.Now(), meta), nil
+}
+
+// OrderFlow summarizes trades in the window ending at now. Prices and
+// quantities are normalized with meta, or left in chain units when it is nil.
+// Trades are expected oldest first, as the store keeps them.
+func OrderFlow(trades []*models.Trade, window string, now time.Time, meta *MarketMeta) *models.OrderFlow {
+	from := now.Add(-FlowWindows[window])
+	flow := &models.OrderFlow{
+		Window: window,
+		From:   from.UTC(),
+		To:     now.UTC(),
+	}
+
+	sizes := make([]float64, 0, len(trades))
+	for _, trade := range trades {
+		if trade.Timestamp.Before(from) || trade.Timestamp.After(now) {
+			continue
+		}
+
+		price, quantity := trade.Price, trade.Quantity
+		if meta != nil {
+			price, quantity = meta.NormalizePrice(price), meta.NormalizeQuantity(quantity)
+		}
+
+		if trade.IsBuy {
+			flow.BuyTrades++
+			flow.BuyVolume += quantity
+			flow.BuyNotional += price * quantity
+		} else {
+			flow.SellTrades++
+			flow.SellVolume += quantity
+			flow.SellNotional += price * quantity
+		}
+		sizes = append(sizes, quantity)
+	}
+
+	// A full cache starting inside the window has dropped its older trades
+	if len(trades) >= cache.MaxTrades && trades[0].Timestamp.After(from) {
+		flow.Truncated = true
+	}
+
+	sort.Float64s(sizes)
+	summarizeFlow(flow, sizes)
+
+	return flow
+}
+
+// summarizeFlow sets the trade count and the derived fields of a flow from
+// its buy and sell totals and the sorted sizes of its trades
+func summarizeFlow(flow *models.OrderFlow, sizes []float64) {
+	flow.Trades = len(sizes)
+	if flow.Trades == 0 {
+		return
+	}
+
+	volume := flow.BuyVolume + flow.SellVolume
+	notional := flow.BuyNotional + flow.SellNotional
+
+	flow.AvgTradeSize = volume / float64(flow.Trades)
+	flow.MedianTradeSize = median(sizes)
+	flow.CVD = flow.BuyVolume - flow.SellVolume
+	if volume > 0 {
+		flow.VWAP = notional / volume
+	}
+	if notional > 0 {
+		flow.TakerImbalance = (flow.BuyNotional - flow.SellNotional) / notional
+	}
+}
+
+// median returns the median of sorted values
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// FlowWindow keeps the order flow of the trades within a window, updated as
+// trades arrive rather than rescanned. Like the store, it keeps at most
+// cache.MaxTrades trades. Amounts are in chain units.
+type FlowWindow struct {
+	window    string
+	length    time.Duration
+	trades    []*models.Trade // Sorted by timestamp, oldest first
+	sizes     []float64       // Quantities of trades, sorted for the median
+	totals    models.OrderFlow
+	droppedAt time.Time // Newest trade dropped to stay within cache.MaxTrades
+}
+
+// NewFlowWindow creates an empty window of one of the FlowWindows
+func NewFlowWindow(window string) *FlowWindow {
+	return &FlowWindow{
+		window: window,
+		length: FlowWindows[window],
+	}
+}
+
+// Add adds a newly stored trade. Trades arriving out of order are placed by
+// timestamp, so they expire with the trades of their time.
+func (w *FlowWindow) Add(trade *models.Trade) {
+	i := sort.Search(len(w.trades), func(i int) bool {
+		return w.trades[i].Timestamp.After(trade.Timestamp)
+	})
+	w.trades = append(w.trades, nil)
+	copy(w.trades[i+1:], w.trades[i:])
+	w.trades[i] = trade
+
+	w.sizes = insertSorted(w.sizes, trade.Quantity)
+	applyTrade(&w.totals, trade, 1)
+
+	if len(w.trades) > cache.MaxTrades {
+		w.droppedAt = w.trades[0].Timestamp
+		w.removeOldest()
+	}
+}
+
+// Flow returns the order flow of the window ending at now, expiring trades
+// that left it. Trades stamped after now are kept but not counted.
+func (w *FlowWindow) Flow(now time.Time) *models.OrderFlow {
+	from := now.Add(-w.length)
+	for len(w.trades) > 0 && w.trades[0].Timestamp.Before(from) {
+		w.removeOldest()
+	}
+
+	flow := w.totals
+	sizes := w.sizes
+	if n := len(w.trades); n > 0 && w.trades[n-1].Timestamp.After(now) {
+		sizes = append([]float64(nil), w.sizes...)
+		for i := n - 1; i >= 0 && w.trades[i].Timestamp.After(now); i-- {
+			applyTrade(&flow, w.trades[i], -1)
+			sizes = removeSorted(sizes, w.trades[i].Quantity)
+		}
+	}
+
+	flow.Window = w.window
+	flow.From = from.UTC()
+	flow.To = now.UTC()
+	flow.Truncated = !w.droppedAt.IsZero() && !w.droppedAt.Before(from)
+	summarizeFlow(&flow, sizes)
+
+	return &flow
+}
+
+// removeOldest takes the oldest trade out of the window
+func (w *FlowWindow) removeOldest() {
+	trade := w.trades[0]
+	w.trades[0] = nil
+	w.trades = w.trades[1:]
+
+	w.sizes = removeSorted(w.sizes, trade.Quantity)
+
+	if len(w.trades) == 0 {
+		// Start over to shed rounding errors
+		w.totals = models.OrderFlow{}
+		return
+	}
+	applyTrade(&w.totals, trade, -1)
+}
+
+// applyTrade adds (sign 1) or removes (sign -1) a trade from buy or sell totals
+func applyTrade(flow *models.OrderFlow, trade *models.Trade, sign int) {
+	quantity := float64(sign) * trade.Quantity
+	if trade.IsBuy {
+		flow.BuyTrades += sign
+		flow.BuyVolume += quantity
+		flow.BuyNotional += trade.Price * quantity
+	} else {
+		flow.SellTrades += sign
+		flow.SellVolume += quantity
+		flow.SellNotional += trade.Price * quantity
+	}
+}
+
+// insertSorted inserts x into an ascending slice
+func insertSorted(sorted []float64, x float64) []float64 {
+	i := sort.SearchFloat64s(sorted, x)
+	sorted = append(sorted, 0)
+	copy(sorted[i+1:], sorted[i:])
+	sorted[i] = x
+	return sorted
+}
+
+// removeSorted removes one occurrence of x from an ascending slice
+func removeSorted(sorted []float64, x float64) []float64 {
+	i := sort.SearchFloat64s(sorted, x)
+	return append(sorted[:i], sorted[i+1:]...)
+}
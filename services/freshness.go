@@ -0,0 +1,128 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+var (
+	// analyticsFreshFor is how long analytics are served without revalidating
+	analyticsFreshFor = 15 * time.Second
+	// analyticsMaxAge is the oldest analytics served while revalidating
+	analyticsMaxAge = 2 * time.Minute
+
+	// revalidating holds markets with a background recompute in flight
+	revalidating sync.Map
+)
+
+// InitAnalyticsFreshness sets how long analytics are fresh and the hard
+// maximum age up to which stale analytics are still served
+func InitAnalyticsFreshness(freshFor, maxAge time.Duration) {
+	if freshFor > 0 {
+		analyticsFreshFor = freshFor
+	}
+	if maxAge < analyticsFreshFor {
+		maxAge = analyticsFreshFor
+	}
+	analyticsMaxAge = maxAge
+}
+
+// dataAsOf returns when the data behind analytics was observed
+func dataAsOf(analytics *models.MarketAnalytics) time.Time {
+	if !analytics.DataAsOf.IsZero() {
+		return analytics.DataAsOf
+	}
+	return analytics.Timestamp
+}
+
+// servable reports whether analytics are young enough to serve at all.
+// Analytics restored from a snapshot are held to the same max age.
+func servable(analytics *models.MarketAnalytics) bool {
+	return time.Since(dataAsOf(analytics)) <= analyticsMaxAge
+}
+
+// stamped returns a copy of analytics with age, stale flag, liquidity score
+// and trending score set for serving
+func stamped(analytics *models.MarketAnalytics) *models.MarketAnalytics {
+	copied := *analytics
+	copied.DataAsOf = dataAsOf(analytics)
+
+	age := time.Since(copied.DataAsOf)
+	copied.AgeMs = age.Milliseconds()
+	copied.Stale = analytics.Restored || age > analyticsFreshFor
+
+	scoreLiquidity(&copied)
+	scoreTrending(&copied)
+
+	return &copied
+}
+
+// revalidate recomputes analytics of a market in the background, at most
+// once at a time per market
+func revalidate(marketID string) {
+	if _, busy := revalidating.LoadOrStore(marketID, true); busy {
+		return
+	}
+
+	go func() {
+		defer revalidating.Delete(marketID)
+
+		if refreshAnalytics(marketID) == nil {
+			log.Printf("Revalidating analytics for %s failed, serving stale data", marketID)
+		}
+	}()
+}
+
+// refreshAnalytics fetches the orderbook if it isn't cached, then computes
+// and stores analytics for a market
+func refreshAnalytics(marketID string) *models.MarketAnalytics {
+	if _, err := GetOrderbook(marketID); err != nil {
+		return nil
+	}
+
+	analytics := ComputeMarketAnalytics(marketID, dataCache)
+	if analytics != nil {
+		dataCache.SetAnalytics(marketID, analytics)
+	}
+
+	return analytics
+}
+
+// SummarizeFreshness describes a list of analytics by its oldest entry: the
+// list is stale if any entry is
+func SummarizeFreshness(list []*models.MarketAnalytics) models.Freshness {
+	all := make([]models.Freshness, len(list))
+	for i, analytics := range list {
+		all[i] = analytics.Freshness
+	}
+	return oldest(all)
+}
+
+// SummarizeTrendingFreshness returns the freshness of the oldest entry of a
+// trending ranking, stale if any entry is
+func SummarizeTrendingFreshness(list []*models.TrendingMarket) models.Freshness {
+	all := make([]models.Freshness, len(list))
+	for i, market := range list {
+		all[i] = market.Freshness
+	}
+	return oldest(all)
+}
+
+// oldest returns the oldest of a list of freshness values, stale if any is.
+// An empty list has no data and is stale.
+func oldest(list []models.Freshness) models.Freshness {
+	summary := models.Freshness{Stale: len(list) == 0}
+
+	for _, freshness := range list {
+		if summary.DataAsOf.IsZero() || freshness.DataAsOf.Before(summary.DataAsOf) {
+			summary.DataAsOf = freshness.DataAsOf
+			summary.AgeMs = freshness.AgeMs
+		}
+		summary.Stale = summary.Stale || freshness.Stale
+	}
+
+	return summary
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"time"
+
+	"github.com/daiwikmh/origami/tsdb"
+)
+
+var history *tsdb.DB
+
+// InitHistoryStore sets the time-series store used for 24h metrics. Without
+// one, analytics fall back to the in-memory price history and cached trades.
+func InitHistoryStore(db *tsdb.DB) {
+	history = db
+}
+
+// historyVolume24h returns the traded volume of a market over the last 24h
+// from the time-series store
+func historyVolume24h(marketID string) (volume float64, ok bool) {
+	if history == nil {
+		return 0, false
+	}
+
+	now := time.Now()
+	aggregates, err := history.Aggregates(marketID, tsdb.FiveMinutes, now.Add(-24*time.Hour), now)
+	if err != nil || len(aggregates) == 0 {
+		return 0, false
+	}
+
+	for _, a := range aggregates {
+		volume += a.Volume
+	}
+
+	return volume, true
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+	"github.com/daiwikmh/origami/utils"
+)
+
+// SimulateImpact walks the cached orderbook of a market to fill a market
+// order of side "buy" or "sell", sized either by base quantity or by quote
+// notional (set the other to zero). Amounts are in token units.
+func SimulateImpact(marketID, side string, quantity, notional float64) (*models.PriceImpact, error) {
+	meta, err := GetMarketMeta(marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	orderbook, err := GetOrderbook(marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	impact := &models.PriceImpact{
+		MarketID:     marketID,
+		Side:         side,
+		Quantity:     quantity,
+		Notional:     notional,
+		TakerFeeRate: meta.TakerFeeRate,
+	}
+
+	if dataCache != nil {
+		if entry, found := dataCache.GetOrderbookEntry(marketID); found {
+			impact.OrderbookAsOf = entry.StoredAt
+		}
+	}
+	if impact.OrderbookAsOf.IsZero() {
+		impact.OrderbookAsOf = time.Now()
+	}
+
+	buys, sells := orderbookLevels(orderbook)
+	if len(buys) > 0 && len(sells) > 0 {
+		impact.MidPrice = meta.NormalizePrice((buys[0].Price + sells[0].Price) / 2)
+	}
+
+	// A buy takes asks, a sell takes bids
+	levels := sells
+	if side == "sell" {
+		levels = buys
+	}
+
+	for _, level := range levels {
+		remaining := quantity - impact.FilledQuantity
+		if notional > 0 {
+			remaining = notional - impact.FilledNotional
+		}
+		if remaining <= 0 {
+			break
+		}
+
+		price := meta.NormalizePrice(level.Price)
+		available := meta.NormalizeQuantity(level.Quantity)
+		if price <= 0 || available <= 0 {
+			continue
+		}
+
+		take := min(available, remaining)
+		if notional > 0 {
+			take = min(available, remaining/price)
+		}
+
+		if impact.LevelsConsumed == 0 {
+			impact.BestPrice = price
+		}
+		impact.FilledQuantity += take
+		impact.FilledNotional += take * price
+		impact.WorstPrice = price
+		impact.LevelsConsumed++
+	}
+
+	// Allow for float error when the last level exactly completes the order
+	if notional > 0 {
+		impact.Fillable = impact.FilledNotional >= notional*(1-1e-9)
+	} else {
+		impact.Fillable = impact.FilledQuantity >= quantity*(1-1e-9)
+	}
+
+	if impact.FilledQuantity == 0 {
+		return impact, nil
+	}
+
+	impact.AvgPrice = impact.FilledNotional / impact.FilledQuantity
+	impact.TakerFee = impact.FilledNotional * meta.TakerFeeRate
+
+	// Fees raise the price paid by a buyer and lower the price received by a seller
+	if side == "sell" {
+		impact.EffectivePrice = impact.AvgPrice * (1 - meta.TakerFeeRate)
+	} else {
+		impact.EffectivePrice = impact.AvgPrice * (1 + meta.TakerFeeRate)
+	}
+
+	impact.SlippageBps = adverseBps(side, impact.AvgPrice, impact.MidPrice)
+	impact.PriceImpactBps = adverseBps(side, impact.WorstPrice, impact.MidPrice)
+	impact.EffectiveSlippageBps = adverseBps(side, impact.EffectivePrice, impact.MidPrice)
+
+	return impact, nil
+}
+
+// adverseBps returns how far price is from mid in basis points, positive when
+// the price is worse for the side
+func adverseBps(side string, price, mid float64) float64 {
+	bps := utils.BasisPoints(price, mid)
+	if side == "sell" {
+		return -bps
+	}
+	return bps
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/daiwikmh/origami/cache"
+	"github.com/daiwikmh/origami/models"
+)
+
+// RollingWindow keeps the sum, mean and variance of the last Size values,
+// updated in constant time per value (Welford's algorithm, with removal)
+type RollingWindow struct {
+	values []float64 // Ring buffer
+	start  int
+	count  int
+	sum    float64
+	mean   float64
+	m2     float64 // Sum of squared deviations from the mean
+}
+
+// NewRollingWindow creates an empty window of the given size
+func NewRollingWindow(size int) *RollingWindow {
+	if size < 1 {
+		size = 1
+	}
+	return &RollingWindow{values: make([]float64, size)}
+}
+
+// Add appends a value, dropping the oldest one when the window is full
+func (w *RollingWindow) Add(x float64) {
+	if w.count == len(w.values) {
+		w.remove(w.values[w.start])
+		w.start = (w.start + 1) % len(w.values)
+	}
+
+	w.values[(w.start+w.count)%len(w.values)] = x
+	w.count++
+	w.sum += x
+
+	delta := x - w.mean
+	w.mean += delta / float64(w.count)
+	w.m2 += delta * (x - w.mean)
+}
+
+// remove takes the oldest value out of the running statistics
+func (w *RollingWindow) remove(x float64) {
+	w.count--
+	w.sum -= x
+	if w.count == 0 {
+		w.mean, w.m2, w.sum = 0, 0, 0
+		return
+	}
+
+	delta := x - w.mean
+	w.mean -= delta / float64(w.count)
+	w.m2 -= delta * (x - w.mean)
+	if w.m2 < 0 {
+		w.m2 = 0
+	}
+}
+
+// Len returns the number of values in the window
+func (w *RollingWindow) Len() int {
+	return w.count
+}
+
+// Sum returns the sum of the values in the window
+func (w *RollingWindow) Sum() float64 {
+	return w.sum
+}
+
+// StdDev returns the population standard deviation of the window
+func (w *RollingWindow) StdDev() float64 {
+	if w.count == 0 {
+		return 0
+	}
+	return math.Sqrt(w.m2 / float64(w.count))
+}
+
+// AnalyticsState is the running input of a market's analytics: the log
+// returns of the price history window, the notional of cached trades and
+// their order flow. It is fed new data as it arrives, so recomputing
+// analytics doesn't rescan prices and trades. A state is not safe for
+// concurrent use.
+type AnalyticsState struct {
+	MarketID string
+
+	returns     *RollingWindow // Squared log returns between history points
+	gaps        *RollingWindow // Seconds between history points
+	windowSize  int            // Size of the price history window
+	lastPrice   float64
+	lastPriceAt time.Time
+	volume      *RollingWindow // Notional of the trades kept by the store
+	flow        *FlowWindow    // Order flow of the trades in analyticsFlowWindow
+	lastTradeAt *time.Time
+}
+
+// NewAnalyticsState builds the state of a market from the store
+func NewAnalyticsState(marketID string, store cache.MarketStore) *AnalyticsState {
+	s := &AnalyticsState{
+		MarketID: marketID,
+		volume:   NewRollingWindow(cache.MaxTrades),
+		flow:     NewFlowWindow(analyticsFlowWindow),
+	}
+
+	s.SyncPrices(store)
+
+	if trades, found := store.GetTrades(marketID); found {
+		for _, trade := range trades {
+			s.AddTrade(trade)
+		}
+	}
+
+	return s
+}
+
+// SyncPrices adds price history points newer than the last one seen
+func (s *AnalyticsState) SyncPrices(store cache.MarketStore) {
+	history, found := store.GetPriceHistory(s.MarketID)
+	if !found || len(history.Prices) != len(history.Times) {
+		return
+	}
+
+	if s.returns == nil || s.windowSize != history.MaxSize {
+		s.windowSize = history.MaxSize
+		s.returns = NewRollingWindow(history.MaxSize - 1)
+		s.gaps = NewRollingWindow(history.MaxSize - 1)
+		s.lastPrice, s.lastPriceAt = 0, time.Time{}
+	}
+
+	for i, t := range history.Times {
+		if !t.After(s.lastPriceAt) {
+			continue
+		}
+
+		price := history.Prices[i]
+		if s.lastPrice > 0 && price > 0 {
+			r := math.Log(price / s.lastPrice)
+			s.returns.Add(r * r)
+			s.gaps.Add(t.Sub(s.lastPriceAt).Seconds())
+		}
+
+		s.lastPrice, s.lastPriceAt = price, t
+	}
+}
+
+// AddTrade adds the notional and order flow of a newly stored trade
+func (s *AnalyticsState) AddTrade(trade *models.Trade) {
+	s.volume.Add(trade.Price * trade.Quantity)
+	s.flow.Add(trade)
+
+	if s.lastTradeAt == nil || trade.Timestamp.After(*s.lastTradeAt) {
+		newest := trade.Timestamp
+		s.lastTradeAt = &newest
+	}
+}
+
+// volatility returns the annualized realized volatility of the price history
+func (s *AnalyticsState) volatility() float64 {
+	if s.returns == nil {
+		return 0
+	}
+	return annualize(s.returns.Sum(), s.gaps.Sum())
+}
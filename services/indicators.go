@@ -0,0 +1,405 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+const (
+	// indicatorBars is how many candles indicators are computed over. Bars
+	// beyond the longest period warm up the smoothed indicators.
+	indicatorBars = 1000
+
+	// MaxIndicatorPeriod bounds the period of an indicator
+	MaxIndicatorPeriod = 200
+
+	// MaxIndicators bounds the indicators of one request
+	MaxIndicators = 10
+)
+
+// Indicator kinds
+const (
+	IndicatorRSI  = "rsi"  // Relative strength index, Wilder smoothing
+	IndicatorEMA  = "ema"  // Exponential moving average of closes
+	IndicatorMACD = "macd" // MACD 12/26 with a 9 bar signal line
+	IndicatorBB   = "bb"   // Bollinger Bands, 2 standard deviations
+	IndicatorATR  = "atr"  // Average true range, Wilder smoothing
+)
+
+// defaultIndicatorPeriods is the period of kinds named without one
+var defaultIndicatorPeriods = map[string]int{
+	IndicatorRSI: 14,
+	IndicatorEMA: 20,
+	IndicatorBB:  20,
+	IndicatorATR: 14,
+}
+
+var (
+	// ErrUnknownIndicator is returned for indicators that are not supported or
+	// have a period outside 2..MaxIndicatorPeriod
+	ErrUnknownIndicator = errors.New("unknown indicator")
+	// ErrTooManyIndicators is returned for sets of more than MaxIndicators
+	ErrTooManyIndicators = errors.New("too many indicators")
+)
+
+// IndicatorSpec is a parsed indicator name such as rsi14
+type IndicatorSpec struct {
+	Name   string
+	Kind   string
+	Period int
+}
+
+// ParseIndicatorSet parses a comma-separated list of indicators. Periods follow
+// the kind (ema50); kinds without one use their default (rsi is rsi14).
+func ParseIndicatorSet(set string) ([]IndicatorSpec, error) {
+	specs := make([]IndicatorSpec, 0)
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(set, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		kind := strings.TrimRight(name, "0123456789")
+		spec := IndicatorSpec{Name: name, Kind: kind}
+
+		if kind == IndicatorMACD {
+			if kind != name {
+				return nil, ErrUnknownIndicator
+			}
+		} else {
+			period, known := defaultIndicatorPeriods[kind]
+			if !known {
+				return nil, ErrUnknownIndicator
+			}
+			if digits := name[len(kind):]; digits != "" {
+				var err error
+				if period, err = strconv.Atoi(digits); err != nil || period < 2 || period > MaxIndicatorPeriod {
+					return nil, ErrUnknownIndicator
+				}
+			}
+			spec.Period = period
+		}
+
+		specs = append(specs, spec)
+	}
+
+	if len(specs) > MaxIndicators {
+		return nil, ErrTooManyIndicators
+	}
+	return specs, nil
+}
+
+// indicatorCache keeps candles and computed indicator series per market and interval
+type indicatorCache struct {
+	entries map[string]*indicatorEntry // Market ID and interval
+	mu      sync.Mutex
+}
+
+// indicatorEntry is the cached state of one market at one interval
+type indicatorEntry struct {
+	computedAt time.Time
+	expiresAt  time.Time
+	candles    []*models.Candle
+	series     map[string][]models.IndicatorValue // By indicator name
+	loaded     bool
+	err        error
+	mu         sync.Mutex // Guards loading and series
+}
+
+var indicators = &indicatorCache{entries: make(map[string]*indicatorEntry)}
+
+// indicatorTTL is how long indicators of an interval are served from cache:
+// a twelfth of the bar width, between 5 seconds and a minute
+func indicatorTTL(width time.Duration) time.Duration {
+	return min(max(width/12, 5*time.Second), time.Minute)
+}
+
+// entry returns the live cache entry of a market and interval, replacing an
+// expired one and dropping other expired entries
+func (ic *indicatorCache) entry(marketID, interval string, ttl time.Duration) *indicatorEntry {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	now := time.Now()
+	key := marketID + "|" + interval
+
+	if e, exists := ic.entries[key]; exists && now.Before(e.expiresAt) {
+		return e
+	}
+
+	for k, e := range ic.entries {
+		if !now.Before(e.expiresAt) {
+			delete(ic.entries, k)
+		}
+	}
+
+	e := &indicatorEntry{
+		computedAt: now,
+		expiresAt:  now.Add(ttl),
+		series:     make(map[string][]models.IndicatorValue),
+	}
+	ic.entries[key] = e
+	return e
+}
+
+// GetIndicators computes indicators of a market from its candles at an
+// interval. Each indicator's latest value is returned along with its last
+// seriesLen values. Candles and computed series are cached per interval.
+func GetIndicators(marketID, interval string, specs []IndicatorSpec, seriesLen int) (*models.IndicatorSet, error) {
+	width, exists := CandleIntervals[interval]
+	if !exists {
+		return nil, ErrUnknownInterval
+	}
+
+	if _, err := GetMarketMeta(marketID); err != nil {
+		return nil, err
+	}
+
+	e := indicators.entry(marketID, interval, indicatorTTL(width))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.loaded {
+		to := e.computedAt
+		e.candles, e.err = GetCandles(marketID, interval, to.Add(-(indicatorBars-1)*width), to)
+		e.loaded = true
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	result := &models.IndicatorSet{
+		MarketID:   marketID,
+		Interval:   interval,
+		Bars:       len(e.candles),
+		ComputedAt: e.computedAt.UTC(),
+		Indicators: make([]*models.Indicator, 0, len(specs)),
+	}
+
+	for _, spec := range specs {
+		series, computed := e.series[spec.Name]
+		if !computed {
+			series = computeIndicator(spec, e.candles)
+			e.series[spec.Name] = series
+		}
+
+		indicator := &models.Indicator{Name: spec.Name}
+		if n := len(series); n > 0 {
+			latest := series[n-1]
+			indicator.Latest = &latest
+			if seriesLen > 0 {
+				indicator.Series = series[max(n-seriesLen, 0):]
+			}
+		} else {
+			indicator.InsufficientHistory = true
+		}
+
+		result.Indicators = append(result.Indicators, indicator)
+	}
+
+	return result, nil
+}
+
+// computeIndicator returns the values of an indicator for every bar it is defined on
+func computeIndicator(spec IndicatorSpec, candles []*models.Candle) []models.IndicatorValue {
+	closes := make([]float64, len(candles))
+	for i, candle := range candles {
+		closes[i] = candle.Close
+	}
+
+	// Lines hold one value per candle; NaN where the indicator is undefined
+	var lines map[string][]float64
+
+	switch spec.Kind {
+	case IndicatorRSI:
+		lines = map[string][]float64{"rsi": rsi(closes, spec.Period)}
+	case IndicatorEMA:
+		lines = map[string][]float64{"ema": ema(closes, spec.Period)}
+	case IndicatorMACD:
+		fast, slow := ema(closes, 12), ema(closes, 26)
+		line := make([]float64, len(closes))
+		for i := range line {
+			line[i] = fast[i] - slow[i]
+		}
+		signal := ema(line, 9)
+		histogram := make([]float64, len(closes))
+		for i := range histogram {
+			histogram[i] = line[i] - signal[i]
+		}
+		lines = map[string][]float64{"macd": line, "signal": signal, "histogram": histogram}
+	case IndicatorBB:
+		upper, middle, lower := bollinger(closes, spec.Period, 2)
+		bandwidth := make([]float64, len(closes))
+		for i := range bandwidth {
+			bandwidth[i] = (upper[i] - lower[i]) / middle[i]
+		}
+		lines = map[string][]float64{"upper": upper, "middle": middle, "lower": lower, "bandwidth": bandwidth}
+	case IndicatorATR:
+		lines = map[string][]float64{"atr": atr(candles, spec.Period)}
+	}
+
+	values := make([]models.IndicatorValue, 0)
+	for i, candle := range candles {
+		point := models.IndicatorValue{
+			Time:    candle.Time,
+			Partial: candle.Partial,
+			Values:  make(map[string]float64, len(lines)),
+		}
+
+		defined := true
+		for name, line := range lines {
+			if math.IsNaN(line[i]) || math.IsInf(line[i], 0) {
+				defined = false
+				break
+			}
+			point.Values[name] = line[i]
+		}
+
+		if defined {
+			values = append(values, point)
+		}
+	}
+
+	return values
+}
+
+// undefined returns a line of n NaN values
+func undefined(n int) []float64 {
+	line := make([]float64, n)
+	for i := range line {
+		line[i] = math.NaN()
+	}
+	return line
+}
+
+// ema returns the exponential moving average of values, seeded with the simple
+// average of the first period values. Leading NaNs are skipped.
+func ema(values []float64, period int) []float64 {
+	line := undefined(len(values))
+
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+	if len(values)-start < period {
+		return line
+	}
+
+	var sum float64
+	for _, v := range values[start : start+period] {
+		sum += v
+	}
+
+	alpha := 2 / float64(period+1)
+	current := sum / float64(period)
+	line[start+period-1] = current
+
+	for i := start + period; i < len(values); i++ {
+		current += alpha * (values[i] - current)
+		line[i] = current
+	}
+
+	return line
+}
+
+// rsi returns the relative strength index of closes with Wilder smoothing
+func rsi(closes []float64, period int) []float64 {
+	line := undefined(len(closes))
+	if len(closes) <= period {
+		return line
+	}
+
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := closes[i] - closes[i-1]
+		gain += max(change, 0)
+		loss += max(-change, 0)
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	line[period] = relativeStrength(gain, loss)
+
+	for i := period + 1; i < len(closes); i++ {
+		change := closes[i] - closes[i-1]
+		gain = (gain*float64(period-1) + max(change, 0)) / float64(period)
+		loss = (loss*float64(period-1) + max(-change, 0)) / float64(period)
+		line[i] = relativeStrength(gain, loss)
+	}
+
+	return line
+}
+
+// relativeStrength converts average gain and loss to an RSI value; a flat
+// market is neutral at 50
+func relativeStrength(gain, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// bollinger returns bands k population standard deviations around the simple
+// moving average of closes
+func bollinger(closes []float64, period int, k float64) (upper, middle, lower []float64) {
+	upper, middle, lower = undefined(len(closes)), undefined(len(closes)), undefined(len(closes))
+
+	for i := period - 1; i < len(closes); i++ {
+		window := closes[i-period+1 : i+1]
+
+		var sum float64
+		for _, v := range window {
+			sum += v
+		}
+		mean := sum / float64(period)
+
+		var squares float64
+		for _, v := range window {
+			squares += (v - mean) * (v - mean)
+		}
+		band := k * math.Sqrt(squares/float64(period))
+
+		upper[i], middle[i], lower[i] = mean+band, mean, mean-band
+	}
+
+	return upper, middle, lower
+}
+
+// atr returns the average true range of candles with Wilder smoothing
+func atr(candles []*models.Candle, period int) []float64 {
+	line := undefined(len(candles))
+	if len(candles) <= period {
+		return line
+	}
+
+	trueRange := func(i int) float64 {
+		previous := candles[i-1].Close
+		return max(candles[i].High-candles[i].Low, math.Abs(candles[i].High-previous), math.Abs(candles[i].Low-previous))
+	}
+
+	var current float64
+	for i := 1; i <= period; i++ {
+		current += trueRange(i)
+	}
+	current /= float64(period)
+	line[period] = current
+
+	for i := period + 1; i < len(candles); i++ {
+		current = (current*float64(period-1) + trueRange(i)) / float64(period)
+		line[i] = current
+	}
+
+	return line
+}
@@ -0,0 +1,107 @@
+package services
+
+import "github.com/daiwikmh/origami/models"
+
+const (
+	// Weights of the liquidity score components
+	liquiditySpreadWeight   = 0.35
+	liquidityDepthWeight    = 0.40
+	liquidityActivityWeight = 0.25
+
+	// liquidityDepthBand is the percentage band around mid measured for depth
+	liquidityDepthBand = 2.0
+)
+
+// usdQuotes are the quote tokens taken as worth one USD
+var usdQuotes = map[string]bool{"USDT": true, "USDC": true, "USD": true}
+
+// measureLiquidity returns the unscored liquidity components of a market.
+// Amounts are converted to USD through the quote token's price; markets
+// whose quote token has no USD price are flagged unpriced and measured in
+// their quote token.
+func measureLiquidity(marketID string, depth *models.OrderbookDepth, volume24h float64) *models.Liquidity {
+	meta, err := GetMarketMeta(marketID)
+	if err != nil || depth == nil {
+		return nil
+	}
+
+	rate, priced := quoteUSDRate(meta)
+	conversion := rate
+	if !priced {
+		conversion = 1
+	}
+
+	var depthNotional float64
+	for _, band := range depth.Bands {
+		if band.Percent == liquidityDepthBand {
+			depthNotional = band.BidNotional + band.AskNotional
+		}
+	}
+
+	return &models.Liquidity{
+		Spread:       models.LiquidityComponent{Value: depth.SpreadBps, Weight: liquiditySpreadWeight},
+		Depth:        models.LiquidityComponent{Value: meta.NormalizeNotional(depthNotional) * conversion, Weight: liquidityDepthWeight},
+		Activity:     models.LiquidityComponent{Value: meta.NormalizeNotional(volume24h) * conversion, Weight: liquidityActivityWeight},
+		QuoteUSDRate: rate,
+		Unpriced:     !priced,
+	}
+}
+
+// quoteUSDRate returns the USD value of one quote token of a market: one for
+// USD stablecoins, otherwise the current price of a market trading the quote
+// token against one
+func quoteUSDRate(meta *MarketMeta) (float64, bool) {
+	if usdQuotes[meta.QuoteSymbol] {
+		return 1, true
+	}
+
+	var rate float64
+	findMarketMeta(func(ref *MarketMeta) bool {
+		if ref.BaseSymbol != meta.QuoteSymbol || !usdQuotes[ref.QuoteSymbol] || dataCache == nil {
+			return false
+		}
+
+		analytics, found := dataCache.GetAnalytics(ref.MarketID)
+		if !found || !servable(analytics) || analytics.CurrentPrice <= 0 {
+			return false
+		}
+
+		rate = ref.NormalizePrice(analytics.CurrentPrice)
+		return true
+	})
+
+	return rate, rate > 0
+}
+
+// scoreLiquidity sets the component scores and overall score of analytics
+// being served, ranking their components against all servable markets.
+// Unpriced markets can't be compared and score zero.
+func scoreLiquidity(analytics *models.MarketAnalytics) {
+	if analytics.Liquidity == nil {
+		// Not measured, e.g. restored from a snapshot of an older version
+		analytics.LiquidityScore = 0
+		return
+	}
+
+	u := getUniverse()
+
+	if analytics.Liquidity.Unpriced {
+		scored := *analytics.Liquidity
+		scored.Universe = u.count(metricDepth)
+		analytics.Liquidity = &scored
+		analytics.LiquidityScore = 0
+		return
+	}
+
+	scored := *analytics.Liquidity
+	scored.Spread.Score = 100 - u.percentile(metricSpread, scored.Spread.Value)
+	scored.Depth.Score = u.percentile(metricDepth, scored.Depth.Value)
+	scored.Activity.Score = u.percentile(metricActivity, scored.Activity.Value)
+	scored.Score = scored.Spread.Score*scored.Spread.Weight +
+		scored.Depth.Score*scored.Depth.Weight +
+		scored.Activity.Score*scored.Activity.Weight
+	scored.Universe = u.count(metricDepth)
+
+	analytics.Liquidity = &scored
+	analytics.LiquidityScore = scored.Score
+}
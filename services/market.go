@@ -9,11 +9,29 @@ import (
 	"github.com/daiwikmh/origami/models"
 )
 
-var dataCache *cache.DataCache
+var dataCache cache.MarketStore
 
-// InitMarketService initializes the market service with cache
-func InitMarketService(cache *cache.DataCache) {
-	dataCache = cache
+// InitMarketService initializes the market service with a market store
+func InitMarketService(store cache.MarketStore) {
+	dataCache = store
+}
+
+// GetCacheStats returns the size of the in-memory market store, if it is the one in use
+func GetCacheStats() (cache.CacheStats, bool) {
+	store := dataCache
+	for {
+		wrapper, ok := store.(interface{ Unwrap() cache.MarketStore })
+		if !ok {
+			break
+		}
+		store = wrapper.Unwrap()
+	}
+
+	memory, ok := store.(*cache.DataCache)
+	if !ok {
+		return cache.CacheStats{}, false
+	}
+	return memory.Stats(), true
 }
 
 func GetMarkets() (map[string]interface{}, error) {
@@ -24,12 +42,16 @@ func GetMarkets() (map[string]interface{}, error) {
 		}
 	}
 
-	// Fallback to API
-	data, err := clients.FetchMarkets()
-	if err == nil && dataCache != nil {
-		dataCache.SetMarkets(data, 10*time.Second)
-	}
+	// Fallback to API, one fetch for all concurrent callers
+	value, err, _ := marketsFetches.Do("markets", func() (interface{}, error) {
+		data, err := clients.FetchMarkets()
+		if err == nil && dataCache != nil {
+			dataCache.SetMarkets(data, 10*time.Second)
+		}
+		return data, err
+	})
 
+	data, _ := value.(map[string]interface{})
 	return data, err
 }
 
@@ -41,12 +63,28 @@ func GetOrderbook(marketID string) (map[string]interface{}, error) {
 		}
 	}
 
-	// Fallback to API
-	data, err := clients.FetchOrderbook(marketID)
-	if err == nil && dataCache != nil {
-		dataCache.SetOrderbook(marketID, data, 5*time.Second)
+	if unknownMarkets.has(marketID) {
+		return nil, ErrMarketNotFound
 	}
 
+	// Fallback to API, one fetch for all concurrent callers
+	value, err, _ := orderbookFetches.Do(marketID, func() (interface{}, error) {
+		// Don't spend an upstream fetch on IDs that aren't listed
+		if _, err := GetMarketMeta(marketID); err != nil {
+			if err == ErrMarketNotFound {
+				unknownMarkets.add(marketID)
+			}
+			return nil, err
+		}
+
+		data, err := clients.FetchOrderbook(marketID)
+		if err == nil && dataCache != nil {
+			dataCache.SetOrderbook(marketID, data, 5*time.Second)
+		}
+		return data, err
+	})
+
+	data, _ := value.(map[string]interface{})
 	return data, err
 }
 
@@ -56,14 +94,23 @@ func GetMarketAnalytics(marketID string) *models.MarketAnalytics {
 		return nil
 	}
 
-	// Try to get from cache
+	// Serve cached analytics, revalidating in the background once they are
+	// no longer fresh
 	analytics, found := dataCache.GetAnalytics(marketID)
-	if found {
-		return analytics
+	if found && servable(analytics) {
+		served := stamped(analytics)
+		if served.Stale {
+			revalidate(marketID)
+		}
+		return served
 	}
 
-	// Compute on-demand if not cached
-	return ComputeMarketAnalytics(marketID, dataCache)
+	// Compute on-demand if not cached or past the hard max age
+	if computed := refreshAnalytics(marketID); computed != nil {
+		return stamped(computed)
+	}
+
+	return nil
 }
 
 // GetTopMarkets returns top markets sorted by specified criteria
@@ -72,7 +119,12 @@ func GetTopMarkets(sortBy string, limit int) []*models.MarketAnalytics {
 		return nil
 	}
 
-	allAnalytics := dataCache.GetAllAnalytics()
+	allAnalytics := make([]*models.MarketAnalytics, 0)
+	for _, analytics := range dataCache.GetAllAnalytics() {
+		if servable(analytics) {
+			allAnalytics = append(allAnalytics, stamped(analytics))
+		}
+	}
 
 	// Sort based on criteria
 	switch sortBy {
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/daiwikmh/origami/utils"
+)
+
+// ErrMarketNotFound is returned for market IDs missing from the market list
+var ErrMarketNotFound = errors.New("market not found")
+
+// MarketMeta holds the static properties of a spot market
+type MarketMeta struct {
+	MarketID      string
+	Ticker        string
+	BaseDenom     string
+	QuoteDenom    string
+	BaseSymbol    string
+	QuoteSymbol   string
+	BaseDecimals  int
+	QuoteDecimals int
+	MakerFeeRate  float64
+	TakerFeeRate  float64
+}
+
+// GetMarketMeta looks a market up in the market list
+func GetMarketMeta(marketID string) (*MarketMeta, error) {
+	data, err := GetMarkets()
+	if err != nil {
+		return nil, err
+	}
+
+	markets, _ := data["markets"].([]interface{})
+	for _, m := range markets {
+		market, ok := m.(map[string]interface{})
+		if !ok || utils.ParseString(market["marketId"]) != marketID {
+			continue
+		}
+
+		return parseMarketMeta(market), nil
+	}
+
+	return nil, ErrMarketNotFound
+}
+
+// findMarketMeta returns the first market in the market list matching fn
+func findMarketMeta(fn func(meta *MarketMeta) bool) *MarketMeta {
+	data, err := GetMarkets()
+	if err != nil {
+		return nil
+	}
+
+	markets, _ := data["markets"].([]interface{})
+	for _, m := range markets {
+		market, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if meta := parseMarketMeta(market); fn(meta) {
+			return meta
+		}
+	}
+
+	return nil
+}
+
+// parseMarketMeta reads the properties of a market list entry
+func parseMarketMeta(market map[string]interface{}) *MarketMeta {
+	meta := &MarketMeta{
+		MarketID:   utils.ParseString(market["marketId"]),
+		Ticker:     utils.ParseString(market["ticker"]),
+		BaseDenom:  utils.ParseString(market["baseDenom"]),
+		QuoteDenom: utils.ParseString(market["quoteDenom"]),
+	}
+	meta.BaseDecimals = tokenDecimals(market["baseTokenMeta"])
+	meta.QuoteDecimals = tokenDecimals(market["quoteTokenMeta"])
+	meta.BaseSymbol, meta.QuoteSymbol = tokenSymbols(market)
+	meta.MakerFeeRate, _ = utils.ParseFloat(market["makerFeeRate"])
+	meta.TakerFeeRate, _ = utils.ParseFloat(market["takerFeeRate"])
+
+	return meta
+}
+
+// NormalizePrice converts a chain price (quote base units per base base unit)
+// to a human price (quote tokens per base token)
+func (m *MarketMeta) NormalizePrice(price float64) float64 {
+	return price * math.Pow10(m.BaseDecimals-m.QuoteDecimals)
+}
+
+// NormalizeQuantity converts a chain quantity to base tokens
+func (m *MarketMeta) NormalizeQuantity(quantity float64) float64 {
+	return quantity / math.Pow10(m.BaseDecimals)
+}
+
+// NormalizeNotional converts a chain notional (price times quantity) to quote tokens
+func (m *MarketMeta) NormalizeNotional(notional float64) float64 {
+	return notional / math.Pow10(m.QuoteDecimals)
+}
+
+// tokenSymbols reads the upper-case base and quote symbols of a market from its
+// token metas, falling back to the ticker ("INJ/USDT")
+func tokenSymbols(market map[string]interface{}) (base, quote string) {
+	base, quote, _ = strings.Cut(utils.ParseString(market["ticker"]), "/")
+
+	if meta, ok := market["baseTokenMeta"].(map[string]interface{}); ok && utils.ParseString(meta["symbol"]) != "" {
+		base = utils.ParseString(meta["symbol"])
+	}
+	if meta, ok := market["quoteTokenMeta"].(map[string]interface{}); ok && utils.ParseString(meta["symbol"]) != "" {
+		quote = utils.ParseString(meta["symbol"])
+	}
+
+	return strings.ToUpper(strings.TrimSpace(base)), strings.ToUpper(strings.TrimSpace(quote))
+}
+
+// tokenDecimals reads the decimals of a token meta object, zero if missing
+func tokenDecimals(tokenMeta interface{}) int {
+	meta, ok := tokenMeta.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+
+	decimals, err := utils.ParseInt(meta["decimals"])
+	if err != nil {
+		return 0
+	}
+	return decimals
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/daiwikmh/origami/utils"
+)
+
+// orderbookLevels parses both sides of an orderbook, best price first
+func orderbookLevels(orderbookData interface{}) (buys, sells []utils.OrderLevel) {
+	obMap, ok := orderbookData.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	orderbook, ok := obMap["orderbook"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	buys, _ = utils.ExtractOrderbookLevels(orderbook, "buys")
+	sells, _ = utils.ExtractOrderbookLevels(orderbook, "sells")
+
+	sort.SliceStable(buys, func(i, j int) bool { return buys[i].Price > buys[j].Price })
+	sort.SliceStable(sells, func(i, j int) bool { return sells[i].Price < sells[j].Price })
+
+	return buys, sells
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+	"github.com/daiwikmh/origami/tsdb"
+	"github.com/daiwikmh/origami/utils"
+)
+
+// priceHorizon is a lookback and the rollup its reference price is read from
+type priceHorizon struct {
+	name       string
+	length     time.Duration
+	resolution tsdb.Resolution
+}
+
+// priceHorizons are the horizons of price changes, shortest first
+var priceHorizons = []priceHorizon{
+	{"5m", 5 * time.Minute, tsdb.Minute},
+	{"1h", time.Hour, tsdb.Minute},
+	{"4h", 4 * time.Hour, tsdb.Minute},
+	{"24h", 24 * time.Hour, tsdb.Minute},
+	{"7d", 7 * 24 * time.Hour, tsdb.FiveMinutes},
+}
+
+// reference is a price observed at a time
+type reference struct {
+	price float64
+	time  time.Time
+}
+
+// referenceCache keeps the stored reference of each market and horizon for
+// the rollup bucket it was looked up in, until a late sample amends the
+// market's closed buckets
+type referenceCache struct {
+	entries map[string]cachedReference // Market ID and horizon
+	mu      sync.Mutex
+}
+
+// cachedReference is a reference lookup, nil when none was found
+type cachedReference struct {
+	bucket   time.Time
+	revision uint64
+	ref      *reference
+}
+
+var references = &referenceCache{entries: make(map[string]cachedReference)}
+
+// tolerance is how long before the start of a horizon the reference price
+// may have been observed: a tenth of the horizon, at least one rollup bucket
+func (h priceHorizon) tolerance() time.Duration {
+	return max(h.length/10, h.resolution.Step())
+}
+
+// PriceChanges returns the change of currentPrice over each horizon. The
+// reference is the last price observed at or before the start of the horizon,
+// from the time-series store or, without one, the in-memory price history.
+// Horizons without an observation within their tolerance are flagged.
+func PriceChanges(marketID string, currentPrice float64, now time.Time) []models.PriceChange {
+	changes := make([]models.PriceChange, 0, len(priceHorizons))
+
+	for _, h := range priceHorizons {
+		change := models.PriceChange{Horizon: h.name}
+
+		ref := referencePrice(marketID, h, now)
+		if ref == nil || ref.price <= 0 || currentPrice <= 0 {
+			change.InsufficientHistory = true
+			changes = append(changes, change)
+			continue
+		}
+
+		delta := currentPrice - ref.price
+		pct := utils.PercentageChange(ref.price, currentPrice)
+		refPrice, refTime := ref.price, ref.time.UTC()
+
+		change.Change = &delta
+		change.ChangePct = &pct
+		change.ReferencePrice = &refPrice
+		change.ReferenceTime = &refTime
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// referencePrice finds the price at the start of a horizon
+func referencePrice(marketID string, h priceHorizon, now time.Time) *reference {
+	start := now.Add(-h.length)
+
+	if history == nil {
+		return memoryReference(marketID, start, h.tolerance())
+	}
+
+	// Closed buckets only change with late samples, so a lookup holds for
+	// the bucket until the market's revision changes
+	bucket := start.UTC().Truncate(h.resolution.Step())
+	revision := history.Revision(marketID)
+	key := marketID + "|" + h.name
+
+	references.mu.Lock()
+	cached, found := references.entries[key]
+	references.mu.Unlock()
+	if found && cached.bucket.Equal(bucket) && cached.revision == revision {
+		return cached.ref
+	}
+
+	ref, err := storedReference(marketID, h, start)
+	if err != nil {
+		return nil
+	}
+
+	references.mu.Lock()
+	references.entries[key] = cachedReference{bucket: bucket, revision: revision, ref: ref}
+	references.mu.Unlock()
+
+	return ref
+}
+
+// storedReference returns the close of the last rollup bucket that ended at
+// or before start, observed within the horizon's tolerance
+func storedReference(marketID string, h priceHorizon, start time.Time) (*reference, error) {
+	step := h.resolution.Step()
+
+	aggregates, err := history.Aggregates(marketID, h.resolution, start.Add(-h.tolerance()-step), start)
+	if err != nil && !errors.Is(err, tsdb.ErrInvalidMarketID) {
+		return nil, err
+	}
+
+	for i := len(aggregates) - 1; i >= 0; i-- {
+		a := aggregates[i]
+		end := a.Start.Add(step)
+		if a.Samples == 0 || end.After(start) {
+			continue
+		}
+		if start.Sub(a.Last) > h.tolerance() {
+			break
+		}
+		return &reference{price: a.Close, time: a.Last}, nil
+	}
+
+	return nil, nil
+}
+
+// memoryReference returns the last in-memory price history point at or
+// before start, within tolerance
+func memoryReference(marketID string, start time.Time, tolerance time.Duration) *reference {
+	if dataCache == nil {
+		return nil
+	}
+
+	prices, found := dataCache.GetPriceHistory(marketID)
+	if !found {
+		return nil
+	}
+
+	for i := len(prices.Times) - 1; i >= 0 && i < len(prices.Prices); i-- {
+		t := prices.Times[i]
+		if t.After(start) {
+			continue
+		}
+		if start.Sub(t) > tolerance {
+			break
+		}
+		return &reference{price: prices.Prices[i], time: t}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// ErrInvalidCursor is returned for cursors not produced by GetTradeTape
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TradeFilter selects trades from the tape. Zero values don't filter.
+type TradeFilter struct {
+	From        time.Time
+	To          time.Time
+	Side        string  // "buy" or "sell"
+	MinNotional float64 // In quote tokens
+	Cursor      string  // Continue after the last trade of a previous page
+	Limit       int
+}
+
+// GetTradeTape returns cached trades of a market, newest first, normalized to
+// token units. The returned cursor fetches the next (older) page and is empty
+// on the last page.
+func GetTradeTape(marketID string, filter TradeFilter) ([]*models.TapeTrade, string, error) {
+	meta, err := GetMarketMeta(marketID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var after *tradeKey
+	if filter.Cursor != "" {
+		key, err := decodeTradeCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = &key
+	}
+
+	var trades []*models.Trade
+	if dataCache != nil {
+		trades, _ = dataCache.GetTrades(marketID)
+	}
+
+	// Newest first, trade ID breaks ties so pages are stable
+	sorted := append([]*models.Trade(nil), trades...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return keyOf(sorted[j]).before(keyOf(sorted[i]))
+	})
+
+	result := make([]*models.TapeTrade, 0, filter.Limit)
+	var last tradeKey
+	for _, trade := range sorted {
+		if after != nil && !keyOf(trade).before(*after) {
+			continue
+		}
+		if !filter.From.IsZero() && trade.Timestamp.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && trade.Timestamp.After(filter.To) {
+			continue
+		}
+
+		side := "sell"
+		if trade.IsBuy {
+			side = "buy"
+		}
+		if filter.Side != "" && filter.Side != side {
+			continue
+		}
+
+		price := meta.NormalizePrice(trade.Price)
+		quantity := meta.NormalizeQuantity(trade.Quantity)
+		notional := price * quantity
+		if notional < filter.MinNotional {
+			continue
+		}
+
+		if len(result) == filter.Limit {
+			// Another match exists, so there is a next page
+			return result, encodeTradeCursor(last), nil
+		}
+
+		result = append(result, &models.TapeTrade{
+			TradeID:   trade.TradeID,
+			Timestamp: trade.Timestamp,
+			Side:      side,
+			Price:     price,
+			Quantity:  quantity,
+			Notional:  notional,
+		})
+		last = keyOf(trade)
+	}
+
+	return result, "", nil
+}
+
+// tradeKey orders trades by time, then trade ID
+type tradeKey struct {
+	nanos int64
+	id    string
+}
+
+func keyOf(trade *models.Trade) tradeKey {
+	return tradeKey{nanos: trade.Timestamp.UnixNano(), id: trade.TradeID}
+}
+
+// before reports whether k sorts before other (is older)
+func (k tradeKey) before(other tradeKey) bool {
+	if k.nanos != other.nanos {
+		return k.nanos < other.nanos
+	}
+	return k.id < other.id
+}
+
+// encodeTradeCursor makes an opaque cursor from a trade key
+func encodeTradeCursor(key tradeKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(key.nanos, 10) + ":" + key.id))
+}
+
+// decodeTradeCursor parses a cursor made by encodeTradeCursor
+func decodeTradeCursor(cursor string) (tradeKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return tradeKey{}, ErrInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return tradeKey{}, ErrInvalidCursor
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return tradeKey{}, ErrInvalidCursor
+	}
+
+	return tradeKey{nanos: n, id: id}, nil
+}
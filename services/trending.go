@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// Trending score methods
+const (
+	TrendingPercentile = "percentile" // Components are percentile ranks, scores range 0-100
+	TrendingZScore     = "zscore"     // Components are z-scores, scores center on 0
+)
+
+// trendingMetrics maps trending components to the cross-market metric they score
+var trendingMetrics = map[string]string{
+	"volume":       metricActivity,
+	"volatility":   metricVolatility,
+	"price_change": metricPriceChange,
+}
+
+// TrendingWeights are the weights of trending components by name, summing to 1
+type TrendingWeights map[string]float64
+
+var (
+	trendingWeights = TrendingWeights{"volume": 0.4, "volatility": 0.3, "price_change": 0.3}
+	trendingMethod  = TrendingPercentile
+)
+
+var (
+	// ErrInvalidWeights is returned for weight lists ParseTrendingWeights rejects
+	ErrInvalidWeights = errors.New("invalid trending weights")
+	// ErrUnknownMethod is returned for methods other than the Trending constants
+	ErrUnknownMethod = errors.New("unknown trending method")
+)
+
+// InitTrending sets the default weights and method of trending scores
+func InitTrending(weights TrendingWeights, method string) {
+	trendingWeights = weights
+	trendingMethod = method
+}
+
+// DefaultTrending returns the default weights and method of trending scores
+func DefaultTrending() (TrendingWeights, string) {
+	return trendingWeights, trendingMethod
+}
+
+// ParseTrendingWeights parses weights such as "volume:0.5,volatility:0.5".
+// Components left out weigh zero. Weights are scaled to sum to 1.
+func ParseTrendingWeights(value string) (TrendingWeights, error) {
+	weights := make(TrendingWeights)
+	var total float64
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, weightStr, found := strings.Cut(part, ":")
+		name = strings.TrimSpace(name)
+		if _, known := trendingMetrics[name]; !found || !known {
+			return nil, ErrInvalidWeights
+		}
+		if _, repeated := weights[name]; repeated {
+			return nil, ErrInvalidWeights
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(weightStr), 64)
+		if err != nil || !(weight >= 0) || weight > 1e6 {
+			return nil, ErrInvalidWeights
+		}
+
+		weights[name] = weight
+		total += weight
+	}
+
+	if total == 0 {
+		return nil, ErrInvalidWeights
+	}
+
+	for name := range weights {
+		weights[name] /= total
+	}
+	return weights, nil
+}
+
+// ParseTrendingMethod checks a trending method name
+func ParseTrendingMethod(method string) (string, error) {
+	if method != TrendingPercentile && method != TrendingZScore {
+		return "", ErrUnknownMethod
+	}
+	return method, nil
+}
+
+// trendingScore scores the trending components of analytics against the
+// universe. Components without a measurement, such as the volume of markets
+// whose quote token has no USD price, are marked unavailable and their weight
+// is shared among the others. Returns false if no weighted component is
+// available.
+func trendingScore(analytics *models.MarketAnalytics, u *universe, weights TrendingWeights, method string) (float64, map[string]models.TrendingComponent, bool) {
+	metrics := marketMetrics(analytics)
+
+	var available float64
+	for name, metric := range trendingMetrics {
+		if _, measured := metrics[metric]; measured {
+			available += weights[name]
+		}
+	}
+	if available == 0 {
+		return 0, nil, false
+	}
+
+	var score float64
+	components := make(map[string]models.TrendingComponent, len(trendingMetrics))
+
+	for name, metric := range trendingMetrics {
+		value, measured := metrics[metric]
+		if !measured {
+			components[name] = models.TrendingComponent{Unavailable: true}
+			continue
+		}
+
+		component := models.TrendingComponent{
+			Value:  value,
+			Weight: weights[name] / available,
+		}
+
+		if method == TrendingZScore {
+			component.Score = u.zscore(metric, component.Value)
+		} else {
+			component.Score = u.percentile(metric, component.Value)
+		}
+		component.Contribution = component.Score * component.Weight
+
+		score += component.Contribution
+		components[name] = component
+	}
+
+	return score, components, true
+}
+
+// scoreTrending sets the trending score of analytics being served, with the
+// default weights and method
+func scoreTrending(analytics *models.MarketAnalytics) {
+	analytics.TrendingScore, _, _ = trendingScore(analytics, getUniverse(), trendingWeights, trendingMethod)
+}
+
+// GetTrendingMarkets returns the top markets by trending score with the given
+// weights and method, each with its component breakdown
+func GetTrendingMarkets(limit int, weights TrendingWeights, method string) []*models.TrendingMarket {
+	trending := make([]*models.TrendingMarket, 0)
+	if dataCache == nil {
+		return trending
+	}
+
+	u := getUniverse()
+
+	for _, analytics := range dataCache.GetAllAnalytics() {
+		if !servable(analytics) {
+			continue
+		}
+
+		served := stamped(analytics)
+		score, components, ok := trendingScore(served, u, weights, method)
+		if !ok {
+			continue
+		}
+
+		var volume *float64
+		if component := components["volume"]; !component.Unavailable {
+			volume = &component.Value
+		}
+
+		symbol := ""
+		if meta, err := GetMarketMeta(served.MarketID); err == nil {
+			symbol = meta.Ticker
+		}
+
+		trending = append(trending, &models.TrendingMarket{
+			MarketID:    served.MarketID,
+			Symbol:      symbol,
+			Score:       score,
+			Volume24h:   volume,
+			Volatility:  served.Volatility,
+			PriceChange: served.PriceChange24hPct,
+			Components:  components,
+			Freshness:   served.Freshness,
+		})
+	}
+
+	sort.Slice(trending, func(i, j int) bool {
+		return trending[i].Score > trending[j].Score
+	})
+
+	if len(trending) > limit {
+		trending = trending[:limit]
+	}
+
+	return trending
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// universeTTL is how long the cross-market values that scores are ranked
+// against are reused
+const universeTTL = 10 * time.Second
+
+// Metrics collected across markets for relative scores
+const (
+	metricSpread      = "spread"       // Spread in bps
+	metricDepth       = "depth"        // USD within the liquidity depth band
+	metricActivity    = "activity"     // USD traded in 24h
+	metricVolatility  = "volatility"   // Annualized volatility
+	metricPriceChange = "price_change" // Absolute 24h price change in percent
+)
+
+// universe is a snapshot of metrics of all servable markets
+type universe struct {
+	sorted map[string][]float64 // Ascending values per metric
+	mean   map[string]float64
+	stddev map[string]float64
+}
+
+var (
+	currentUniverse *universe
+	universeBuiltAt time.Time
+	universeMu      sync.Mutex
+)
+
+// marketMetrics returns the cross-market metrics of analytics. The USD
+// amounts of liquidity are left out when they aren't measured or the quote
+// token has no USD price.
+func marketMetrics(analytics *models.MarketAnalytics) map[string]float64 {
+	metrics := map[string]float64{
+		metricVolatility:  analytics.Volatility,
+		metricPriceChange: math.Abs(analytics.PriceChange24hPct),
+	}
+
+	if liquidity := analytics.Liquidity; liquidity != nil {
+		metrics[metricSpread] = liquidity.Spread.Value
+		if !liquidity.Unpriced {
+			metrics[metricDepth] = liquidity.Depth.Value
+			metrics[metricActivity] = liquidity.Activity.Value
+		}
+	}
+
+	return metrics
+}
+
+// getUniverse returns the metrics of all servable markets, rebuilt when
+// older than universeTTL. The result must not be modified.
+func getUniverse() *universe {
+	universeMu.Lock()
+	defer universeMu.Unlock()
+
+	if currentUniverse != nil && time.Since(universeBuiltAt) <= universeTTL {
+		return currentUniverse
+	}
+
+	u := &universe{
+		sorted: make(map[string][]float64),
+		mean:   make(map[string]float64),
+		stddev: make(map[string]float64),
+	}
+
+	if dataCache != nil {
+		for _, analytics := range dataCache.GetAllAnalytics() {
+			if !servable(analytics) {
+				continue
+			}
+			for metric, value := range marketMetrics(analytics) {
+				u.sorted[metric] = append(u.sorted[metric], value)
+			}
+		}
+	}
+
+	for metric, values := range u.sorted {
+		sort.Float64s(values)
+
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		mean := sum / float64(len(values))
+
+		var squares float64
+		for _, v := range values {
+			squares += (v - mean) * (v - mean)
+		}
+
+		u.mean[metric] = mean
+		u.stddev[metric] = math.Sqrt(squares / float64(len(values)))
+	}
+
+	currentUniverse, universeBuiltAt = u, time.Now()
+	return u
+}
+
+// count returns the number of markets with a value of metric
+func (u *universe) count(metric string) int {
+	return len(u.sorted[metric])
+}
+
+// percentile returns the share of markets with a lower value of metric,
+// counting equal values as half, from 0 to 100. A market alone scores 50.
+func (u *universe) percentile(metric string, value float64) float64 {
+	sorted := u.sorted[metric]
+	if len(sorted) == 0 {
+		return 50
+	}
+
+	below := sort.SearchFloat64s(sorted, value)
+	equal := sort.Search(len(sorted), func(i int) bool { return sorted[i] > value }) - below
+	if equal == 0 {
+		// Not part of the universe yet, rank it as if it were
+		return (float64(below) + 0.5) / float64(len(sorted)+1) * 100
+	}
+
+	return (float64(below) + float64(equal)/2) / float64(len(sorted)) * 100
+}
+
+// zscore returns how many standard deviations value is from the mean of
+// metric across markets, zero when all markets are equal
+func (u *universe) zscore(metric string, value float64) float64 {
+	stddev := u.stddev[metric]
+	if stddev == 0 {
+		return 0
+	}
+	return (value - u.mean[metric]) / stddev
+}
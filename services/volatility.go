@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/daiwikmh/origami/models"
+)
+
+// secondsPerYear annualizes volatility; markets trade around the clock
+const secondsPerYear = 365 * 24 * 60 * 60
+
+// Volatility estimators
+const (
+	EstimatorRealized    = "realized"     // Close-to-close log returns
+	EstimatorParkinson   = "parkinson"    // High-low range of each bar
+	EstimatorGarmanKlass = "garman_klass" // Open, high, low and close of each bar
+)
+
+// VolatilityWindow is a lookback and the candle interval it is estimated from
+type VolatilityWindow struct {
+	Length   time.Duration
+	Interval string
+}
+
+// VolatilityWindows maps supported windows to their lookback and bar width
+var VolatilityWindows = map[string]VolatilityWindow{
+	"1h":  {time.Hour, "1m"},
+	"4h":  {4 * time.Hour, "5m"},
+	"24h": {24 * time.Hour, "15m"},
+	"7d":  {7 * 24 * time.Hour, "1h"},
+	"30d": {30 * 24 * time.Hour, "4h"},
+}
+
+var (
+	// ErrUnknownEstimator is returned for estimators other than the Estimator constants
+	ErrUnknownEstimator = errors.New("unknown volatility estimator")
+	// ErrUnknownWindow is returned for windows not in VolatilityWindows or FlowWindows
+	ErrUnknownWindow = errors.New("unknown window")
+)
+
+// RealizedVolatility returns the annualized volatility of timestamped prices
+// from their log returns. Returns are weighted by the time between samples,
+// so gaps in the series don't distort the result.
+func RealizedVolatility(prices []float64, times []time.Time) float64 {
+	var sumSquares, seconds float64
+
+	for i := 1; i < len(prices) && i < len(times); i++ {
+		dt := times[i].Sub(times[i-1]).Seconds()
+		if prices[i] <= 0 || prices[i-1] <= 0 || dt <= 0 {
+			continue
+		}
+
+		r := math.Log(prices[i] / prices[i-1])
+		sumSquares += r * r
+		seconds += dt
+	}
+
+	return annualize(sumSquares, seconds)
+}
+
+// annualize converts a sum of squared log returns over a number of seconds
+// to annualized volatility
+func annualize(sumSquares, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return math.Sqrt(sumSquares / seconds * secondsPerYear)
+}
+
+// EstimateVolatility estimates the annualized volatility of a market over a
+// window from its candles. Bars without data are skipped; the range-based
+// estimators also skip the bar still open.
+func EstimateVolatility(marketID, estimator, window string) (*models.VolatilityEstimate, error) {
+	spec, exists := VolatilityWindows[window]
+	if !exists {
+		return nil, ErrUnknownWindow
+	}
+	if estimator != EstimatorRealized && estimator != EstimatorParkinson && estimator != EstimatorGarmanKlass {
+		return nil, ErrUnknownEstimator
+	}
+
+	if _, err := GetMarketMeta(marketID); err != nil {
+		return nil, err
+	}
+
+	to := time.Now()
+	from := to.Add(-spec.Length)
+	width := CandleIntervals[spec.Interval]
+
+	candles, err := GetCandles(marketID, spec.Interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	bars := make([]*models.Candle, 0, len(candles))
+	for _, candle := range candles {
+		if candle.Source == models.CandleSourceFilled || candle.Low <= 0 || candle.Open <= 0 {
+			continue
+		}
+		if candle.Partial && estimator != EstimatorRealized {
+			continue
+		}
+		bars = append(bars, candle)
+	}
+
+	result := &models.VolatilityEstimate{
+		MarketID:  marketID,
+		Estimator: estimator,
+		Window:    window,
+		Interval:  spec.Interval,
+		From:      from.UTC(),
+		To:        to.UTC(),
+		Bars:      len(bars),
+	}
+
+	var volatility float64
+	enough := false
+
+	switch estimator {
+	case EstimatorRealized:
+		var sumSquares, seconds float64
+		for i := 1; i < len(bars); i++ {
+			r := math.Log(bars[i].Close / bars[i-1].Close)
+			sumSquares += r * r
+			seconds += bars[i].Time.Sub(bars[i-1].Time).Seconds()
+		}
+		volatility = annualize(sumSquares, seconds)
+		enough = len(bars) >= 3
+	case EstimatorParkinson:
+		var sum float64
+		for _, bar := range bars {
+			hl := math.Log(bar.High / bar.Low)
+			sum += hl * hl
+		}
+		if len(bars) > 0 {
+			variance := sum / float64(len(bars)) / (4 * math.Ln2)
+			volatility = annualize(variance, width.Seconds())
+		}
+		enough = len(bars) >= 2
+	case EstimatorGarmanKlass:
+		var sum float64
+		for _, bar := range bars {
+			hl := math.Log(bar.High / bar.Low)
+			co := math.Log(bar.Close / bar.Open)
+			sum += 0.5*hl*hl - (2*math.Ln2-1)*co*co
+		}
+		if len(bars) > 0 {
+			variance := math.Max(sum/float64(len(bars)), 0)
+			volatility = annualize(variance, width.Seconds())
+		}
+		enough = len(bars) >= 2
+	}
+
+	if enough {
+		result.Volatility = &volatility
+	} else {
+		result.InsufficientHistory = true
+	}
+
+	if n := len(bars); n > 0 {
+		asOf := bars[n-1].Time.Add(width)
+		if asOf.After(to) {
+			asOf = to
+		}
+		result.DataAsOf = &asOf
+		result.AgeMs = to.Sub(asOf).Milliseconds()
+		result.Stale = to.Sub(asOf) > width
+	} else {
+		result.Stale = true
+	}
+
+	return result, nil
+}
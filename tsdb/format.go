@@ -0,0 +1,262 @@
+package tsdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Resolution is the granularity of stored data
+type Resolution string
+
+// Stored resolutions. Raw keeps every sample, the others are rollups.
+const (
+	Raw         Resolution = "raw"
+	Minute      Resolution = "1m"
+	FiveMinutes Resolution = "5m"
+	Hour        Resolution = "1h"
+	Day         Resolution = "1d"
+)
+
+// Rollups are the downsampled resolutions, finest first
+var Rollups = []Resolution{Minute, FiveMinutes, Hour, Day}
+
+// Step returns the bucket width of a resolution, zero for raw data
+func (r Resolution) Step() time.Duration {
+	switch r {
+	case Minute:
+		return time.Minute
+	case FiveMinutes:
+		return 5 * time.Minute
+	case Hour:
+		return time.Hour
+	case Day:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// partition returns the file a timestamp is stored in and the partition's end.
+// Fine resolutions get a file per day, hourly rollups per month, daily per year.
+func (r Resolution) partition(t time.Time) (string, time.Time) {
+	t = t.UTC()
+	switch r {
+	case Hour:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start.AddDate(0, 1, 0)
+	case Day:
+		start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006"), start.AddDate(1, 0, 0)
+	default:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+	}
+}
+
+// parsePartition returns the end of the partition stored in a file name
+func (r Resolution) parsePartition(name string) (time.Time, bool) {
+	layout := "2006-01-02"
+	switch r {
+	case Hour:
+		layout = "2006-01"
+	case Day:
+		layout = "2006"
+	}
+
+	start, err := time.Parse(layout, strings.TrimSuffix(name, fileExt))
+	if err != nil {
+		return time.Time{}, false
+	}
+	_, end := r.partition(start)
+	return end, true
+}
+
+// Retention is how long each resolution is kept. Zero keeps data forever.
+type Retention map[Resolution]time.Duration
+
+// DefaultRetention keeps raw samples a week and rollups progressively longer
+func DefaultRetention() Retention {
+	return Retention{
+		Raw:         7 * 24 * time.Hour,
+		Minute:      30 * 24 * time.Hour,
+		FiveMinutes: 90 * 24 * time.Hour,
+		Hour:        365 * 24 * time.Hour,
+		Day:         0,
+	}
+}
+
+// ParseRetention overrides the default retention with a spec such as
+// "raw=72h,1m=720h,1d=0". Unlisted resolutions keep their default.
+func ParseRetention(spec string) (Retention, error) {
+	retention := DefaultRetention()
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(item, "=")
+		res := Resolution(strings.TrimSpace(name))
+		if _, known := retention[res]; !ok || !known {
+			return nil, fmt.Errorf("invalid retention entry %q", item)
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid retention for %s: %q", res, value)
+		}
+		retention[res] = d
+	}
+
+	return retention, nil
+}
+
+// Sample is one observation of a market. Orderbook samples carry mid, spread
+// and depth; trade samples carry only volume and leave Mid at zero.
+type Sample struct {
+	Time   time.Time `json:"time"`
+	Mid    float64   `json:"mid"`
+	Spread float64   `json:"spread"`
+	Depth  float64   `json:"depth"`  // Notional within the top 10 levels of both sides
+	Volume float64   `json:"volume"` // Traded notional since the previous trade sample
+}
+
+// Aggregate summarizes the samples of one bucket
+type Aggregate struct {
+	Start   time.Time `json:"start"`
+	Samples int64     `json:"samples"` // Orderbook samples in the bucket
+	Open    float64   `json:"open"`    // Mid price OHLC
+	High    float64   `json:"high"`
+	Low     float64   `json:"low"`
+	Close   float64   `json:"close"`
+	Spread  float64   `json:"spread"` // Mean spread
+	Depth   float64   `json:"depth"`  // Mean depth
+	Volume  float64   `json:"volume"` // Total traded notional
+	First   time.Time `json:"-"`      // Times of the samples Open and Close were taken from
+	Last    time.Time `json:"-"`
+}
+
+// add folds a sample into the aggregate
+func (a *Aggregate) add(s Sample) {
+	a.Volume += s.Volume
+	if s.Mid <= 0 {
+		return
+	}
+
+	if a.Samples == 0 {
+		a.Open, a.High, a.Low, a.Close = s.Mid, s.Mid, s.Mid, s.Mid
+		a.First, a.Last = s.Time, s.Time
+	}
+	a.High = math.Max(a.High, s.Mid)
+	a.Low = math.Min(a.Low, s.Mid)
+	if s.Time.Before(a.First) {
+		a.Open, a.First = s.Mid, s.Time
+	}
+	if !s.Time.Before(a.Last) {
+		a.Close, a.Last = s.Mid, s.Time
+	}
+
+	n := float64(a.Samples)
+	a.Spread = (a.Spread*n + s.Spread) / (n + 1)
+	a.Depth = (a.Depth*n + s.Depth) / (n + 1)
+	a.Samples++
+}
+
+// merge combines another aggregate of the same bucket into this one. Open and
+// Close follow sample times, records may be written out of order.
+func (a *Aggregate) merge(b Aggregate) {
+	a.Volume += b.Volume
+	if b.Samples == 0 {
+		return
+	}
+	if a.Samples == 0 {
+		volume := a.Volume
+		*a = b
+		a.Volume = volume
+		return
+	}
+
+	a.High = math.Max(a.High, b.High)
+	a.Low = math.Min(a.Low, b.Low)
+	if b.First.Before(a.First) {
+		a.Open, a.First = b.Open, b.First
+	}
+	if !b.Last.Before(a.Last) {
+		a.Close, a.Last = b.Close, b.Last
+	}
+
+	total := float64(a.Samples + b.Samples)
+	a.Spread = (a.Spread*float64(a.Samples) + b.Spread*float64(b.Samples)) / total
+	a.Depth = (a.Depth*float64(a.Samples) + b.Depth*float64(b.Samples)) / total
+	a.Samples += b.Samples
+}
+
+// Fixed-size little-endian records
+const (
+	sampleSize    = 8 + 4*8
+	aggregateSize = 8 + 8 + 7*8 + 2*8
+	fileExt       = ".dat"
+)
+
+func encodeSample(buf []byte, s Sample) []byte {
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(s.Time.UnixNano()))
+	for _, v := range []float64{s.Mid, s.Spread, s.Depth, s.Volume} {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+	return buf
+}
+
+func decodeSample(b []byte) Sample {
+	f := func(i int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b[8+i*8:])) }
+	return Sample{
+		Time:   time.Unix(0, int64(binary.LittleEndian.Uint64(b))).UTC(),
+		Mid:    f(0),
+		Spread: f(1),
+		Depth:  f(2),
+		Volume: f(3),
+	}
+}
+
+func encodeAggregate(buf []byte, a Aggregate) []byte {
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(a.Start.UnixNano()))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(a.Samples))
+	for _, v := range []float64{a.Open, a.High, a.Low, a.Close, a.Spread, a.Depth, a.Volume} {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+	for _, t := range []time.Time{a.First, a.Last} {
+		var nanos int64
+		if !t.IsZero() {
+			nanos = t.UnixNano()
+		}
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(nanos))
+	}
+	return buf
+}
+
+func decodeAggregate(b []byte) Aggregate {
+	f := func(i int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b[16+i*8:])) }
+	t := func(i int) time.Time {
+		nanos := int64(binary.LittleEndian.Uint64(b[72+i*8:]))
+		if nanos == 0 {
+			return time.Time{}
+		}
+		return time.Unix(0, nanos).UTC()
+	}
+	return Aggregate{
+		Start:   time.Unix(0, int64(binary.LittleEndian.Uint64(b))).UTC(),
+		Samples: int64(binary.LittleEndian.Uint64(b[8:])),
+		Open:    f(0),
+		High:    f(1),
+		Low:     f(2),
+		Close:   f(3),
+		Spread:  f(4),
+		Depth:   f(5),
+		Volume:  f(6),
+		First:   t(0),
+		Last:    t(1),
+	}
+}
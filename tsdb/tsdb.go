@@ -0,0 +1,366 @@
+package tsdb
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidMarketID is returned for market IDs that can't be used as a directory name
+var ErrInvalidMarketID = errors.New("tsdb: invalid market id")
+
+// DB is an embedded time-series store for per-market samples.
+//
+// Layout: <dir>/<market id>/<resolution>/<partition>.dat, where each file holds
+// fixed-size records appended in arrival order. Raw samples are written as
+// they arrive; rollup buckets are kept in memory while open and appended when
+// they close. Records of the same bucket written more than once (late samples,
+// a restart mid-bucket) are merged on read.
+type DB struct {
+	dir       string
+	retention Retention
+	open      map[string]map[Resolution]*Aggregate // Open rollup bucket per market
+	revisions map[string]uint64                    // Late price samples per market
+	mu        sync.Mutex
+	stopChan  chan bool
+	wg        sync.WaitGroup
+}
+
+// Open opens or creates a store in dir and starts hourly retention pruning
+func Open(dir string, retention Retention) (*DB, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		dir:       dir,
+		retention: retention,
+		open:      make(map[string]map[Resolution]*Aggregate),
+		revisions: make(map[string]uint64),
+		stopChan:  make(chan bool),
+	}
+
+	db.wg.Add(1)
+	go db.pruneLoop()
+
+	return db, nil
+}
+
+// Append records a sample for a market and updates its rollups
+func (db *DB) Append(marketID string, s Sample) error {
+	if !validMarketID(marketID) {
+		return ErrInvalidMarketID
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.appendRecord(marketID, Raw, s.Time, encodeSample(nil, s)); err != nil {
+		return err
+	}
+
+	buckets := db.open[marketID]
+	if buckets == nil {
+		buckets = make(map[Resolution]*Aggregate)
+		db.open[marketID] = buckets
+	}
+
+	late := false
+	for _, res := range Rollups {
+		start := s.Time.UTC().Truncate(res.Step())
+		current := buckets[res]
+
+		switch {
+		case current == nil || start.After(current.Start):
+			// The sample opens a new bucket, persist the previous one
+			if current != nil {
+				if err := db.writeAggregate(marketID, res, *current); err != nil {
+					return err
+				}
+			}
+			current = &Aggregate{Start: start}
+			buckets[res] = current
+			current.add(s)
+		case start.Equal(current.Start):
+			current.add(s)
+		default:
+			// Late sample for a closed bucket, merged with it on read
+			amendment := Aggregate{Start: start}
+			amendment.add(s)
+			if err := db.writeAggregate(marketID, res, amendment); err != nil {
+				return err
+			}
+			late = true
+		}
+	}
+
+	if late && s.Mid > 0 {
+		db.revisions[marketID]++
+	}
+
+	return nil
+}
+
+// Revision returns a counter that changes whenever a late price sample
+// amends a closed rollup bucket of a market
+func (db *DB) Revision(marketID string) uint64 {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.revisions[marketID]
+}
+
+// Close stops pruning and persists open buckets
+func (db *DB) Close() error {
+	close(db.stopChan)
+	db.wg.Wait()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var firstErr error
+	for marketID, buckets := range db.open {
+		for res, bucket := range buckets {
+			if err := db.writeAggregate(marketID, res, *bucket); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	db.open = make(map[string]map[Resolution]*Aggregate)
+
+	return firstErr
+}
+
+// Prune deletes partitions that ended before their resolution's retention
+func (db *DB) Prune() {
+	now := time.Now()
+
+	markets, err := os.ReadDir(db.dir)
+	if err != nil {
+		log.Printf("Time-series prune failed: %v", err)
+		return
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, market := range markets {
+		if !market.IsDir() {
+			continue
+		}
+
+		for res, keep := range db.retention {
+			if keep <= 0 {
+				continue
+			}
+
+			resDir := filepath.Join(db.dir, market.Name(), string(res))
+			files, err := os.ReadDir(resDir)
+			if err != nil {
+				continue
+			}
+
+			for _, file := range files {
+				end, ok := res.parsePartition(file.Name())
+				if ok && now.Sub(end) > keep {
+					os.Remove(filepath.Join(resDir, file.Name()))
+				}
+			}
+		}
+	}
+}
+
+// pruneLoop runs retention pruning every hour
+func (db *DB) pruneLoop() {
+	defer db.wg.Done()
+
+	db.Prune()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stopChan:
+			return
+		case <-ticker.C:
+			db.Prune()
+		}
+	}
+}
+
+// writeAggregate appends a rollup record (caller must lock)
+func (db *DB) writeAggregate(marketID string, res Resolution, a Aggregate) error {
+	return db.appendRecord(marketID, res, a.Start, encodeAggregate(nil, a))
+}
+
+// appendRecord appends a record to the partition holding t (caller must lock)
+func (db *DB) appendRecord(marketID string, res Resolution, t time.Time, record []byte) error {
+	name, _ := res.partition(t)
+	dir := filepath.Join(db.dir, marketID, string(res))
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, name+fileExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(record); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readPartitions returns the raw records of every partition overlapping [from, to]
+func (db *DB) readPartitions(marketID string, res Resolution, from, to time.Time, recordSize int) ([][]byte, error) {
+	records := make([][]byte, 0)
+
+	for t := from; !t.After(to); {
+		name, end := res.partition(t)
+
+		data, err := os.ReadFile(filepath.Join(db.dir, marketID, string(res), name+fileExt))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		// A torn final record from a crash is ignored
+		for i := 0; i+recordSize <= len(data); i += recordSize {
+			records = append(records, data[i:i+recordSize])
+		}
+
+		t = end
+	}
+
+	return records, nil
+}
+
+// Samples returns raw samples of a market within [from, to], oldest first
+func (db *DB) Samples(marketID string, from, to time.Time) ([]Sample, error) {
+	if !validMarketID(marketID) {
+		return nil, ErrInvalidMarketID
+	}
+
+	db.mu.Lock()
+	records, err := db.readPartitions(marketID, Raw, from, to, sampleSize)
+	db.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]Sample, 0, len(records))
+	for _, record := range records {
+		s := decodeSample(record)
+		if !s.Time.Before(from) && !s.Time.After(to) {
+			samples = append(samples, s)
+		}
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Time.Before(samples[j].Time)
+	})
+
+	return samples, nil
+}
+
+// Aggregates returns the rollup buckets of a market starting within [from, to],
+// oldest first, including the open bucket
+func (db *DB) Aggregates(marketID string, res Resolution, from, to time.Time) ([]Aggregate, error) {
+	if !validMarketID(marketID) {
+		return nil, ErrInvalidMarketID
+	}
+	if res.Step() == 0 {
+		return nil, fmt.Errorf("tsdb: %q is not a rollup resolution", res)
+	}
+
+	from = from.UTC().Truncate(res.Step())
+
+	db.mu.Lock()
+	records, err := db.readPartitions(marketID, res, from, to, aggregateSize)
+	var open *Aggregate
+	if bucket := db.open[marketID][res]; bucket != nil {
+		copied := *bucket
+		open = &copied
+	}
+	db.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	byStart := make(map[int64]*Aggregate)
+	order := make([]int64, 0)
+
+	add := func(a Aggregate) {
+		if a.Start.Before(from) || a.Start.After(to) {
+			return
+		}
+		key := a.Start.UnixNano()
+		if existing, ok := byStart[key]; ok {
+			existing.merge(a)
+			return
+		}
+		copied := a
+		byStart[key] = &copied
+		order = append(order, key)
+	}
+
+	for _, record := range records {
+		add(decodeAggregate(record))
+	}
+	if open != nil {
+		add(*open)
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+
+	result := make([]Aggregate, 0, len(order))
+	for _, key := range order {
+		result = append(result, *byStart[key])
+	}
+
+	return result, nil
+}
+
+// Query returns the rollup buckets of a market within [from, to] at the finest
+// resolution that is still retained at from and yields at most maxPoints buckets
+func (db *DB) Query(marketID string, from, to time.Time, maxPoints int) (Resolution, []Aggregate, error) {
+	res := db.ResolutionFor(from, to, maxPoints)
+	aggregates, err := db.Aggregates(marketID, res, from, to)
+	return res, aggregates, err
+}
+
+// ResolutionFor picks the rollup Query would use for a range
+func (db *DB) ResolutionFor(from, to time.Time, maxPoints int) Resolution {
+	age := time.Since(from)
+
+	for _, res := range Rollups {
+		if keep := db.retention[res]; keep > 0 && age > keep {
+			continue
+		}
+		if maxPoints > 0 && to.Sub(from)/res.Step() > time.Duration(maxPoints) {
+			continue
+		}
+		return res
+	}
+
+	return Day
+}
+
+// validMarketID allows only characters that are safe in a path segment
+func validMarketID(marketID string) bool {
+	if marketID == "" || len(marketID) > 128 {
+		return false
+	}
+	return strings.IndexFunc(marketID, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || r == '-')
+	}) < 0
+}
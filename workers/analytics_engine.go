@@ -0,0 +1,148 @@
+package workers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/daiwikmh/origami/cache"
+	"github.com/daiwikmh/origami/events"
+	"github.com/daiwikmh/origami/services"
+)
+
+// analyticsEventBuffer is the event buffer of the analytics engine. Drops
+// trigger a rebuild of every market's state from the store.
+const analyticsEventBuffer = 4096
+
+// AnalyticsEngine recomputes a market's analytics when its orderbook, trades
+// or price history change. Changes are debounced: the first change schedules
+// a computation after the debounce delay, and later changes until then join
+// it. Each market is computed at most once per minInterval.
+type AnalyticsEngine struct {
+	cache       cache.MarketStore
+	bus         *events.Bus
+	debounce    time.Duration
+	minInterval time.Duration
+	markets     map[string]*marketWork // Owned by the run goroutine
+	stopChan    chan bool
+	wg          sync.WaitGroup
+}
+
+// marketWork is the pending work and running state of one market
+type marketWork struct {
+	state   *services.AnalyticsState
+	dueAt   time.Time // Zero when nothing is pending
+	lastRun time.Time
+	resync  bool // Rebuild state from the store before computing
+}
+
+// NewAnalyticsEngine creates an engine fed by bus
+func NewAnalyticsEngine(dataCache cache.MarketStore, bus *events.Bus, debounce, minInterval time.Duration) *AnalyticsEngine {
+	return &AnalyticsEngine{
+		cache:       dataCache,
+		bus:         bus,
+		debounce:    debounce,
+		minInterval: minInterval,
+		markets:     make(map[string]*marketWork),
+		stopChan:    make(chan bool),
+	}
+}
+
+// Start subscribes to market events and begins computing
+func (ae *AnalyticsEngine) Start() {
+	sub := ae.bus.Subscribe("analytics", events.Filter{
+		Types: []events.Type{events.OrderbookUpdated, events.TradeAppended, events.TradesReplaced, events.PriceHistoryUpdated},
+	}, analyticsEventBuffer, events.DropOldest)
+
+	ae.wg.Add(1)
+	go ae.run(sub)
+}
+
+// Stop ends computation
+func (ae *AnalyticsEngine) Stop() {
+	close(ae.stopChan)
+	ae.wg.Wait()
+}
+
+// run handles events and computes due markets
+func (ae *AnalyticsEngine) run(sub *events.Subscription) {
+	defer ae.wg.Done()
+	defer sub.Close()
+
+	tick := ae.debounce / 2
+	if tick < 50*time.Millisecond {
+		tick = 50 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	var dropped int64
+	for {
+		select {
+		case <-ae.stopChan:
+			return
+		case e := <-sub.C:
+			ae.handle(e)
+		case now := <-ticker.C:
+			// Incremental state missed events, rebuild it
+			if d := sub.Dropped(); d != dropped {
+				dropped = d
+				for _, work := range ae.markets {
+					work.resync = true
+				}
+			}
+			ae.computeDue(now)
+		}
+	}
+}
+
+// handle feeds an event into its market's state and schedules a computation
+func (ae *AnalyticsEngine) handle(e events.Event) {
+	work, exists := ae.markets[e.MarketID]
+	if !exists {
+		// A fresh state is read from the store and already includes this change
+		work = &marketWork{resync: true}
+		ae.markets[e.MarketID] = work
+	}
+
+	if !work.resync {
+		switch e.Type {
+		case events.TradeAppended:
+			if e.Trade != nil {
+				work.state.AddTrade(e.Trade)
+			}
+		case events.TradesReplaced:
+			work.resync = true
+		case events.PriceHistoryUpdated:
+			work.state.SyncPrices(ae.cache)
+		}
+	}
+
+	if work.dueAt.IsZero() {
+		work.dueAt = time.Now().Add(ae.debounce)
+		if next := work.lastRun.Add(ae.minInterval); next.After(work.dueAt) {
+			work.dueAt = next
+		}
+	}
+}
+
+// computeDue computes the markets whose debounce and rate limit have passed
+func (ae *AnalyticsEngine) computeDue(now time.Time) {
+	for marketID, work := range ae.markets {
+		if work.dueAt.IsZero() || work.dueAt.After(now) {
+			continue
+		}
+
+		if work.resync {
+			work.state = services.NewAnalyticsState(marketID, ae.cache)
+			work.resync = false
+		}
+
+		work.dueAt = time.Time{}
+		work.lastRun = now
+
+		// Without a cached orderbook there is nothing to compute, counted as a failure
+		if analytics := work.state.Compute(ae.cache); analytics != nil {
+			ae.cache.SetAnalytics(marketID, analytics)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package workers
 
 import (
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -9,21 +10,26 @@ import (
 	"github.com/daiwikmh/origami/clients"
 	"github.com/daiwikmh/origami/models"
 	"github.com/daiwikmh/origami/services"
+	"github.com/daiwikmh/origami/tsdb"
 	"github.com/daiwikmh/origami/utils"
 )
 
 // DataCollector manages background data collection workers
 type DataCollector struct {
-	cache    *cache.DataCache
-	stopChan chan bool
-	wg       sync.WaitGroup
+	cache     cache.MarketStore
+	history   *tsdb.DB                // Optional time-series store, nil when disabled
+	lastTrade map[string]*tradeCursor // Newest trade seen per market
+	stopChan  chan bool
+	wg        sync.WaitGroup
 }
 
-// NewDataCollector creates a new data collector
-func NewDataCollector(dataCache *cache.DataCache) *DataCollector {
+// NewDataCollector creates a new data collector. history may be nil.
+func NewDataCollector(dataCache cache.MarketStore, history *tsdb.DB) *DataCollector {
 	return &DataCollector{
-		cache:    dataCache,
-		stopChan: make(chan bool),
+		cache:     dataCache,
+		history:   history,
+		lastTrade: make(map[string]*tradeCursor),
+		stopChan:  make(chan bool),
 	}
 }
 
@@ -31,13 +37,12 @@ func NewDataCollector(dataCache *cache.DataCache) *DataCollector {
 func (dc *DataCollector) Start() {
 	log.Println("Starting background workers...")
 
-	dc.wg.Add(5)
+	dc.wg.Add(4)
 
 	go dc.collectMarkets()
 	go dc.collectOrderbooks()
 	go dc.collectTrades()
 	go dc.updatePriceHistory()
-	go dc.computeAnalytics()
 
 	log.Println("Background workers started")
 }
@@ -140,6 +145,7 @@ func (dc *DataCollector) fetchAndCacheOrderbooks() {
 		}
 
 		dc.cache.SetOrderbook(marketID, orderbook, 5*time.Second)
+		dc.recordOrderbook(marketID, orderbook)
 	}
 
 	log.Printf("Orderbooks updated for %d markets", limit)
@@ -224,20 +230,38 @@ func (dc *DataCollector) parseTrades(marketID string, data map[string]interface{
 			continue
 		}
 
-		price, err := utils.ParseFloat(tradeMap["price"])
+		// Maker fills mirror a taker fill of the same match
+		if side := utils.ParseString(tradeMap["executionSide"]); side != "" && side != "taker" {
+			continue
+		}
+
+		// v2 trades nest price, quantity and timestamp in a price level
+		level := tradeMap
+		if nested, ok := tradeMap["price"].(map[string]interface{}); ok {
+			level = nested
+		}
+
+		price, err := utils.ParseFloat(level["price"])
 		if err != nil {
 			continue
 		}
 
-		quantity, err := utils.ParseFloat(tradeMap["quantity"])
+		quantity, err := utils.ParseFloat(level["quantity"])
 		if err != nil {
 			continue
 		}
 
-		timestamp := time.Now() // Would parse from API in production
+		timestamp := parseMillis(tradeMap["executedAt"])
+		if timestamp.IsZero() {
+			timestamp = parseMillis(level["timestamp"])
+		}
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
 		isBuy := utils.ParseString(tradeMap["tradeDirection"]) == "buy"
 
 		trades = append(trades, &models.Trade{
+			TradeID:   utils.ParseString(tradeMap["tradeId"]),
 			MarketID:  marketID,
 			Price:     price,
 			Quantity:  quantity,
@@ -246,7 +270,107 @@ func (dc *DataCollector) parseTrades(marketID string, data map[string]interface{
 		})
 	}
 
-	dc.cache.SetTrades(marketID, trades)
+	// The API returns newest first, the cache keeps oldest first
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Timestamp.Before(trades[j].Timestamp)
+	})
+
+	// Append only unseen trades so the cache accumulates a trade stream
+	for _, trade := range dc.unseenTrades(marketID, trades) {
+		dc.cache.AppendTrade(marketID, trade)
+		dc.recordTrade(marketID, trade)
+	}
+}
+
+// tradeCursor marks the newest trades seen for a market. Several trades can
+// share a millisecond, so the IDs at the newest timestamp are kept too.
+type tradeCursor struct {
+	last time.Time
+	ids  map[string]bool
+}
+
+// unseenTrades filters trades (oldest first) down to those newer than the cursor
+func (dc *DataCollector) unseenTrades(marketID string, trades []*models.Trade) []*models.Trade {
+	cursor, exists := dc.lastTrade[marketID]
+	if !exists {
+		// Resume after trades already cached, e.g. restored from a snapshot
+		cursor = &tradeCursor{ids: make(map[string]bool)}
+		if cached, found := dc.cache.GetTrades(marketID); found {
+			for _, trade := range cached {
+				cursor.advance(trade)
+			}
+		}
+		dc.lastTrade[marketID] = cursor
+	}
+
+	unseen := make([]*models.Trade, 0)
+	for _, trade := range trades {
+		if trade.Timestamp.Before(cursor.last) {
+			continue
+		}
+		if trade.Timestamp.Equal(cursor.last) && (trade.TradeID == "" || cursor.ids[trade.TradeID]) {
+			continue
+		}
+
+		cursor.advance(trade)
+		unseen = append(unseen, trade)
+	}
+
+	return unseen
+}
+
+// advance moves the cursor to a trade at or after it
+func (tc *tradeCursor) advance(trade *models.Trade) {
+	if trade.Timestamp.After(tc.last) {
+		tc.last = trade.Timestamp
+		tc.ids = make(map[string]bool)
+	}
+	if trade.TradeID != "" {
+		tc.ids[trade.TradeID] = true
+	}
+}
+
+// parseMillis converts a millisecond epoch timestamp, zero if missing
+func parseMillis(value interface{}) time.Time {
+	ms, err := utils.ParseFloat(value)
+	if err != nil || ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(ms))
+}
+
+// recordOrderbook writes mid, spread and depth to the time-series store
+func (dc *DataCollector) recordOrderbook(marketID string, orderbook map[string]interface{}) {
+	if dc.history == nil {
+		return
+	}
+
+	depth := services.CalculateOrderbookDepth(orderbook)
+	if depth == nil {
+		return
+	}
+
+	sample := tsdb.Sample{
+		Time:   time.Now(),
+		Mid:    depth.MidPrice,
+		Spread: depth.Spread,
+		Depth:  depth.BidDepth10 + depth.AskDepth10,
+	}
+	if err := dc.history.Append(marketID, sample); err != nil {
+		log.Printf("Error recording history for %s: %v", marketID, err)
+	}
+}
+
+// recordTrade writes the volume of a new trade to the time-series store
+func (dc *DataCollector) recordTrade(marketID string, trade *models.Trade) {
+	if dc.history == nil {
+		return
+	}
+
+	sample := tsdb.Sample{Time: trade.Timestamp, Volume: trade.Price * trade.Quantity}
+	if err := dc.history.Append(marketID, sample); err != nil {
+		log.Printf("Error recording history for %s: %v", marketID, err)
+	}
 }
 
 // updatePriceHistory updates price history from recent data every 60 seconds
@@ -294,10 +418,12 @@ func (dc *DataCollector) extractPriceHistory() {
 			continue
 		}
 
-		// Get or create price history
-		history, exists := dc.cache.GetPriceHistory(marketID)
-		if !exists {
-			history = models.NewPriceHistory(marketID)
+		// Copy or create price history, stored values are never modified
+		history := models.NewPriceHistory(marketID)
+		if stored, exists := dc.cache.GetPriceHistory(marketID); exists {
+			history.Prices = append(history.Prices, stored.Prices...)
+			history.Times = append(history.Times, stored.Times...)
+			history.MaxSize = stored.MaxSize
 		}
 
 		// Try to get price from orderbook
@@ -333,64 +459,3 @@ func (dc *DataCollector) extractMidPrice(orderbookData interface{}) float64 {
 
 	return (buys[0].Price + sells[0].Price) / 2
 }
-
-// computeAnalytics calculates analytics for all markets every 15 seconds
-func (dc *DataCollector) computeAnalytics() {
-	defer dc.wg.Done()
-
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
-
-	// Initial computation
-	time.Sleep(5 * time.Second) // Wait for initial data
-	dc.calculateAllAnalytics()
-
-	for {
-		select {
-		case <-dc.stopChan:
-			return
-		case <-ticker.C:
-			dc.calculateAllAnalytics()
-		}
-	}
-}
-
-func (dc *DataCollector) calculateAllAnalytics() {
-	// Get market list from cache
-	marketsData, found := dc.cache.GetMarkets()
-	if !found {
-		return
-	}
-
-	marketsMap, ok := marketsData.(map[string]interface{})
-	if !ok {
-		return
-	}
-
-	marketsList, ok := marketsMap["markets"].([]interface{})
-	if !ok {
-		return
-	}
-
-	count := 0
-	for _, m := range marketsList {
-		market, ok := m.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		marketID := utils.ParseString(market["marketId"])
-		if marketID == "" {
-			continue
-		}
-
-		// Compute analytics for this market
-		analytics := services.ComputeMarketAnalytics(marketID, dc.cache)
-		if analytics != nil {
-			dc.cache.SetAnalytics(marketID, analytics)
-			count++
-		}
-	}
-
-	log.Printf("Analytics computed for %d markets", count)
-}